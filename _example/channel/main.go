@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -29,14 +30,19 @@ func main() {
 		log.Fatal("Cert Error:", err)
 	}
 
-	notifications := make(chan *apns2.Notification, 100)
-	responses := make(chan *apns2.Response, *count)
-
 	client := apns2.NewClient(cert).Production()
 
-	for i := 0; i < 50; i++ {
-		go worker(client, notifications, responses)
-	}
+	dispatcher := apns2.NewDispatcher(client, &apns2.DispatcherOptions{
+		Workers: 50,
+		Callback: func(result apns2.PushResult) {
+			if result.Err != nil {
+				log.Println("Push Error:", result.Err)
+				return
+			}
+			res := result.Response
+			fmt.Printf("%v %v %v\n", res.StatusCode, res.ApnsID, res.Reason)
+		},
+	})
 
 	for i := 0; i < *count; i++ {
 		n := &apns2.Notification{
@@ -44,24 +50,12 @@ func main() {
 			Topic:       *topic,
 			Payload:     payload.NewPayload().Alert(fmt.Sprintf("Hello! %v", i)),
 		}
-		notifications <- n
-	}
-
-	for i := 0; i < *count; i++ {
-		res := <-responses
-		fmt.Printf("%v %v %v\n", res.StatusCode, res.ApnsID, res.Reason)
+		if err := dispatcher.Enqueue(n); err != nil {
+			log.Fatal("Enqueue Error:", err)
+		}
 	}
 
-	close(notifications)
-	close(responses)
-}
-
-func worker(client *apns2.Client, notifications <-chan *apns2.Notification, responses chan<- *apns2.Response) {
-	for n := range notifications {
-		res, err := client.Push(n)
-		if err != nil {
-			log.Fatal("Push Error:", err)
-		}
-		responses <- res
+	if err := dispatcher.Close(context.Background()); err != nil {
+		log.Fatal("Close Error:", err)
 	}
 }
@@ -0,0 +1,173 @@
+package apns2test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/token"
+)
+
+// TokenMaxAge is the age after which the Server rejects a provider token as
+// ExpiredProviderToken.
+const TokenMaxAge = time.Hour
+
+var errUnknownKeyID = errors.New("apns2test: unknown key id")
+
+type trustedKey struct {
+	teamID string
+	key    *ecdsa.PublicKey
+}
+
+// TrustToken makes the Server accept the provider tokens signed with the
+// AuthKey of t for its KeyID and TeamID.
+func (s *Server) TrustToken(t *token.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[t.KeyID] = trustedKey{teamID: t.TeamID, key: &t.AuthKey.PublicKey}
+}
+
+// TrustCertificate makes the Server accept the connections authenticated with
+// the client certificate.
+func (s *Server) TrustCertificate(certificate tls.Certificate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(certificate.Certificate) > 0 {
+		s.certificates[string(certificate.Certificate[0])] = true
+	}
+}
+
+// authenticate checks the provider credentials of the request. It returns
+// whether the request uses token-based authentication and the reason it must
+// be rejected with, if any.
+func (s *Server) authenticate(r *http.Request) (tokenAuth bool, reason string) {
+	if authorization := r.Header.Get("authorization"); authorization != "" {
+		const prefix = "bearer "
+		if len(authorization) < len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+			return true, apns.ReasonInvalidProviderToken
+		}
+		return true, s.verifyBearer(authorization[len(prefix):])
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		s.mu.Lock()
+		trusted := s.certificates[string(r.TLS.PeerCertificates[0].Raw)]
+		s.mu.Unlock()
+		if !trusted {
+			return false, apns.ReasonBadCertificate
+		}
+		return false, ""
+	}
+	return true, apns.ReasonMissingProviderToken
+}
+
+func (s *Server) verifyBearer(bearer string) string {
+	var key trustedKey
+	claims := jwt.MapClaims{}
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodES256.Alg()}, SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(bearer, claims, func(t *jwt.Token) (interface{}, error) {
+		keyID, _ := t.Header["kid"].(string)
+		s.mu.Lock()
+		trusted, ok := s.keys[keyID]
+		s.mu.Unlock()
+		if !ok {
+			return nil, errUnknownKeyID
+		}
+		key = trusted
+		return trusted.key, nil
+	})
+	if err != nil {
+		return apns.ReasonInvalidProviderToken
+	}
+	if issuer, _ := claims["iss"].(string); issuer != key.teamID {
+		return apns.ReasonInvalidProviderToken
+	}
+	issuedAt, ok := claims["iat"].(float64)
+	if !ok {
+		return apns.ReasonInvalidProviderToken
+	}
+	if time.Since(time.Unix(int64(issuedAt), 0)) > TokenMaxAge {
+		return apns.ReasonExpiredProviderToken
+	}
+	return ""
+}
+
+// NewToken returns a provider token with a freshly generated AuthKey and
+// random KeyID and TeamID.
+func NewToken() *token.Token {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("apns2test: failed to generate the auth key: %v", err))
+	}
+	return &token.Token{
+		AuthKey: key,
+		KeyID:   randomID(),
+		TeamID:  randomID(),
+	}
+}
+
+// NewCertificate returns a freshly generated self-signed client certificate.
+func NewCertificate() tls.Certificate {
+	certificate, err := newCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Apple Push Services: com.apns2test"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		panic(fmt.Sprintf("apns2test: failed to generate the client certificate: %v", err))
+	}
+	return certificate
+}
+
+func newServerCertificate() (tls.Certificate, error) {
+	return newCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "apns2test"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	})
+}
+
+func newCertificate(template *x509.Certificate) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(24 * time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign
+	template.BasicConstraintsValid = true
+	template.IsCA = true
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{raw}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+func randomID() string {
+	b := make([]byte, 5)
+	_, _ = rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
@@ -0,0 +1,219 @@
+package apns2test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+// FakePusher is an apns2.Pusher recording the notifications it is given
+// instead of sending them. Every notification is accepted, unless a response
+// was programmed for it with On or OnDeviceToken.
+type FakePusher struct {
+	mu            sync.Mutex
+	rules         []fakeRule
+	notifications []*apns.Notification
+}
+
+type fakeRule struct {
+	match    func(n *apns.Notification) bool
+	response *apns.Response
+	err      error
+}
+
+var _ apns.Pusher = (*FakePusher)(nil)
+
+// NewFakePusher returns a new FakePusher accepting every notification.
+func NewFakePusher() *FakePusher {
+	return &FakePusher{}
+}
+
+// Rejection returns the Response APNs answers with when rejecting a
+// notification for the reason.
+func Rejection(reason string) *apns.Response {
+	statusCode, ok := ReasonStatus[reason]
+	if !ok {
+		statusCode = http.StatusBadRequest
+	}
+	return &apns.Response{StatusCode: statusCode, Reason: reason}
+}
+
+// On makes the FakePusher answer the notifications for which match returns
+// true with the response and error. Rules are evaluated in the order they were
+// added and the first matching one is used. The ApnsID of the response, if
+// empty, is set to the one of the notification. If both res and err are nil,
+// the matching notifications are accepted.
+func (f *FakePusher) On(match func(n *apns.Notification) bool, res *apns.Response, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, fakeRule{match: match, response: res, err: err})
+}
+
+// OnDeviceToken makes the FakePusher answer the notifications sent to the
+// device token with the response and error. See On.
+func (f *FakePusher) OnDeviceToken(deviceToken string, res *apns.Response, err error) {
+	f.On(func(n *apns.Notification) bool {
+		return n.DeviceToken == deviceToken
+	}, res, err)
+}
+
+// Push records the notification and returns the programmed response.
+func (f *FakePusher) Push(n *apns.Notification) (*apns.Response, error) {
+	return f.PushWithContext(context.Background(), n)
+}
+
+// PushWithContext records the notification and returns the programmed
+// response. If the context is already done, the notification is not recorded
+// and the context error is returned. A nil context is treated as
+// context.Background, as with apns2.Client.
+func (f *FakePusher) PushWithContext(ctx apns.Context, n *apns.Notification) (*apns.Response, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notifications = append(f.notifications, n)
+
+	res := &apns.Response{StatusCode: apns.StatusSent}
+	for _, rule := range f.rules {
+		if rule.match(n) {
+			if rule.err != nil {
+				return nil, rule.err
+			}
+			if rule.response != nil {
+				copied := *rule.response
+				res = &copied
+			}
+			break
+		}
+	}
+	if res.ApnsID == "" {
+		res.ApnsID = n.ApnsID
+	}
+	if res.ApnsID == "" {
+		res.ApnsID = apns.NewApnsID()
+	}
+	res.Attempts = 1
+	return res, nil
+}
+
+// Notifications returns the notifications recorded so far, in order.
+func (f *FakePusher) Notifications() []*apns.Notification {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*apns.Notification(nil), f.notifications...)
+}
+
+// Reset forgets the recorded notifications and the programmed responses.
+func (f *FakePusher) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notifications = nil
+	f.rules = nil
+}
+
+// Match describes the notifications looked for by Find, AssertSent and
+// AssertNotSent. Empty fields match any notification. The alert fields are
+// compared with the ones of the aps dictionary of the JSON payload, a string
+// alert being its body.
+type Match struct {
+	DeviceToken string
+	Topic       string
+	PushType    apns.EPushType
+	AlertTitle  string
+	AlertBody   string
+}
+
+// Matches reports whether the notification matches m.
+func (m Match) Matches(n *apns.Notification) bool {
+	if m.DeviceToken != "" && m.DeviceToken != n.DeviceToken {
+		return false
+	}
+	if m.Topic != "" && m.Topic != n.Topic {
+		return false
+	}
+	if m.PushType != "" && m.PushType != n.PushType {
+		return false
+	}
+	if m.AlertTitle == "" && m.AlertBody == "" {
+		return true
+	}
+	title, body, ok := decodeAlert(n)
+	if !ok {
+		return false
+	}
+	return (m.AlertTitle == "" || m.AlertTitle == title) && (m.AlertBody == "" || m.AlertBody == body)
+}
+
+// Find returns the recorded notifications matching m.
+func (f *FakePusher) Find(m Match) []*apns.Notification {
+	var found []*apns.Notification
+	for _, n := range f.Notifications() {
+		if m.Matches(n) {
+			found = append(found, n)
+		}
+	}
+	return found
+}
+
+// TestingT is the subset of testing.TB used by the assertion helpers.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertSent reports a test error unless a recorded notification matches m,
+// and returns whether the assertion holds.
+func (f *FakePusher) AssertSent(t TestingT, m Match) bool {
+	t.Helper()
+	if len(f.Find(m)) == 0 {
+		t.Errorf("apns2test: none of the %v notifications sent matches %+v", len(f.Notifications()), m)
+		return false
+	}
+	return true
+}
+
+// AssertNotSent reports a test error if a recorded notification matches m,
+// and returns whether the assertion holds.
+func (f *FakePusher) AssertNotSent(t TestingT, m Match) bool {
+	t.Helper()
+	if found := f.Find(m); len(found) > 0 {
+		t.Errorf("apns2test: %v notifications matching %+v were sent", len(found), m)
+		return false
+	}
+	return true
+}
+
+// decodeAlert returns the alert title and body of the notification payload.
+func decodeAlert(n *apns.Notification) (title, body string, ok bool) {
+	raw, err := n.MarshalJSON()
+	if err != nil {
+		return "", "", false
+	}
+	var p struct {
+		APS struct {
+			Alert json.RawMessage `json:"alert"`
+		} `json:"aps"`
+	}
+	if err := json.Unmarshal(raw, &p); err != nil || len(p.APS.Alert) == 0 {
+		return "", "", false
+	}
+	if err := json.Unmarshal(p.APS.Alert, &body); err == nil {
+		return "", body, true
+	}
+	var alert struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+	if err := json.Unmarshal(p.APS.Alert, &alert); err != nil {
+		return "", "", false
+	}
+	return alert.Title, alert.Body, true
+}
@@ -0,0 +1,126 @@
+package apns2test_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/apns2test"
+	"github.com/sapienzaapps/apns2/payload"
+)
+
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestFakePusherDefaultResponse(t *testing.T) {
+	fake := apns2test.NewFakePusher()
+	var pusher apns.Pusher = fake
+	n := mockNotification()
+	n.ApnsID = "84DB694F-464F-49BD-960A-D6DB028335C9"
+	res, err := pusher.Push(n)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if !res.Sent() || n.ApnsID != res.ApnsID {
+		t.Fatal("Expected notification to be sent, found:", res)
+	}
+	if res, _ := pusher.Push(mockNotification()); 36 != len(res.ApnsID) {
+		t.Fatal("Expected a generated apns-id, found:", res.ApnsID)
+	}
+	if 2 != len(fake.Notifications()) || n != fake.Notifications()[0] {
+		t.Fatal("Expected:", 2, " found:", len(fake.Notifications()))
+	}
+}
+
+func TestFakePusherProgrammedResponses(t *testing.T) {
+	fake := apns2test.NewFakePusher()
+	unregistered := strings.Repeat("ab", 32)
+	fake.OnDeviceToken(unregistered, apns2test.Rejection(apns.ReasonUnregistered), nil)
+	failure := errors.New("connection refused")
+	fake.On(func(n *apns.Notification) bool { return n.Topic == "com.failing" }, nil, failure)
+
+	n := mockNotification()
+	n.DeviceToken = unregistered
+	res, err := fake.Push(n)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if apns.ReasonUnregistered != res.Reason || 410 != res.StatusCode || !errors.Is(res.Err(), apns.ErrUnregistered) {
+		t.Fatal("Expected:", apns.ReasonUnregistered, " found:", res.StatusCode, res.Reason)
+	}
+
+	n = mockNotification()
+	n.Topic = "com.failing"
+	if _, err := fake.Push(n); !errors.Is(err, failure) {
+		t.Fatal("Expected:", failure, " found:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fake.PushWithContext(ctx, mockNotification()); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected:", context.Canceled, " found:", err)
+	}
+	if 2 != len(fake.Notifications()) {
+		t.Fatal("Expected:", 2, " found:", len(fake.Notifications()))
+	}
+
+	if res, err := fake.PushWithContext(nil, mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+
+	fake.Reset()
+	fake.On(func(n *apns.Notification) bool { return true }, nil, nil)
+	if res, _ := fake.Push(n); !res.Sent() || 1 != len(fake.Notifications()) {
+		t.Fatal("Expected Reset to forget rules and notifications")
+	}
+}
+
+func TestFakePusherAssertions(t *testing.T) {
+	fake := apns2test.NewFakePusher()
+	n := mockNotification()
+	n.PushType = apns.PushTypeAlert
+	n.Payload = payload.NewPayload().AlertTitle("Hi").AlertBody("Hello!")
+	_, _ = fake.Push(n)
+	n = mockNotification()
+	n.DeviceToken = strings.Repeat("ab", 32)
+	n.Payload = payload.NewPayload().Alert("Plain")
+	_, _ = fake.Push(n)
+
+	fake.AssertSent(t, apns2test.Match{DeviceToken: deviceToken, Topic: "com.apns2test", AlertTitle: "Hi", AlertBody: "Hello!"})
+	fake.AssertSent(t, apns2test.Match{PushType: apns.PushTypeAlert})
+	fake.AssertSent(t, apns2test.Match{DeviceToken: n.DeviceToken, AlertBody: "Plain"})
+	fake.AssertNotSent(t, apns2test.Match{AlertBody: "Bye"})
+	if 2 != len(fake.Find(apns2test.Match{Topic: "com.apns2test"})) {
+		t.Fatal("Expected:", 2, " found:", len(fake.Find(apns2test.Match{Topic: "com.apns2test"})))
+	}
+
+	recorder := &recordingT{}
+	if fake.AssertSent(recorder, apns2test.Match{AlertTitle: "Bye"}) || 1 != len(recorder.errors) {
+		t.Fatal("Expected AssertSent to fail, found:", recorder.errors)
+	}
+	if fake.AssertNotSent(recorder, apns2test.Match{AlertBody: "Plain"}) || 2 != len(recorder.errors) {
+		t.Fatal("Expected AssertNotSent to fail, found:", recorder.errors)
+	}
+}
+
+func TestFakePusherDispatcher(t *testing.T) {
+	fake := apns2test.NewFakePusher()
+	d := apns.NewDispatcher(fake, &apns.DispatcherOptions{Callback: func(apns.PushResult) {}})
+	for i := 0; i < 10; i++ {
+		_ = d.Enqueue(mockNotification())
+	}
+	_ = d.Close(context.Background())
+	if 10 != len(fake.Notifications()) {
+		t.Fatal("Expected:", 10, " found:", len(fake.Notifications()))
+	}
+}
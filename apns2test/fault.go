@@ -0,0 +1,258 @@
+package apns2test
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// FaultAction is the failure a Fault injects at the connection level.
+type FaultAction int
+
+const (
+	// FaultRespond answers the request normally, or with the Reason of the
+	// Fault if set.
+	FaultRespond FaultAction = iota
+
+	// FaultGoAway gracefully shuts down the connection with a GOAWAY frame,
+	// then answers the request. Later requests need a new connection.
+	FaultGoAway
+
+	// FaultResetStream resets the stream with a RST_STREAM frame without
+	// answering the request.
+	FaultResetStream
+
+	// FaultCloseConnection abruptly closes the TCP connection without
+	// answering the request.
+	FaultCloseConnection
+)
+
+// Latency returns the delay to add to a response. It is called once per
+// affected request, so it can draw the delay from a distribution.
+type Latency func() time.Duration
+
+// FixedLatency returns a Latency always delaying responses by d.
+func FixedLatency(d time.Duration) Latency {
+	return func() time.Duration {
+		return d
+	}
+}
+
+// UniformLatency returns a Latency delaying responses by a duration uniformly
+// distributed between min and max.
+func UniformLatency(min, max time.Duration) Latency {
+	return func() time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(rand.Int63n(int64(max-min)))
+	}
+}
+
+// ExponentialLatency returns a Latency delaying responses by an exponentially
+// distributed duration with the given mean, mimicking a long tail.
+func ExponentialLatency(mean time.Duration) Latency {
+	return func() time.Duration {
+		return time.Duration(rand.ExpFloat64() * float64(mean))
+	}
+}
+
+// Fault describes a failure injected by the Server into the requests it
+// receives. Faults are evaluated in the order they were injected, and the
+// first one matching a request is applied to it.
+type Fault struct {
+	// DeviceToken restricts the Fault to the notifications sent to this
+	// device token. If empty, every request matches.
+	DeviceToken string
+
+	// Times is the number of requests the Fault is applied to before being
+	// discarded. If zero, it is applied until ClearFaults is called.
+	Times int
+
+	// Probability is the chance, between 0 and 1, that a matching request is
+	// affected. If zero, every matching request is affected. Requests which
+	// are not affected do not count towards Times.
+	Probability float64
+
+	// Latency, if set, delays the handling of the request.
+	Latency Latency
+
+	// SlowRead, if set, is the pause between the reads of each chunk of the
+	// request body, so that the client is throttled by HTTP/2 flow control.
+	SlowRead time.Duration
+
+	// Reason, if set, is the APNs Reason the request is answered with instead
+	// of being validated, for example ReasonShutdown or ReasonTooManyRequests.
+	Reason string
+
+	// Action is the failure injected at the connection level.
+	Action FaultAction
+}
+
+// slowReadChunk is the size of the chunks read from the request body when
+// SlowRead is set.
+const slowReadChunk = 512
+
+type faultState struct {
+	Fault
+	remaining int
+}
+
+// InjectFault adds the Fault to the ones applied to the incoming requests.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &faultState{Fault: f, remaining: f.Times})
+}
+
+// ClearFaults removes every injected Fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// GoAway gracefully shuts down every open connection with a GOAWAY frame. The
+// requests in flight are answered, while new requests need a new connection.
+func (s *Server) GoAway() {
+	s.mu.Lock()
+	servers := make([]*http.Server, 0, len(s.conns))
+	for _, hs := range s.conns {
+		servers = append(servers, hs)
+	}
+	s.mu.Unlock()
+	for _, hs := range servers {
+		_ = hs.Shutdown(context.Background())
+	}
+}
+
+// CloseConnections abruptly closes every open TCP connection.
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// Blackhole silently drops everything the Server writes to the open
+// connections from now on, as a connection dropped by a NAT would, while
+// still reading the requests. Connections opened later are not affected.
+func (s *Server) Blackhole() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		if c, ok := conn.(*blackholeConn); ok {
+			atomic.StoreInt32(&c.silent, 1)
+		}
+	}
+}
+
+// blackholeConn is a connection whose writes can be silently dropped.
+type blackholeConn struct {
+	net.Conn
+	silent int32
+}
+
+func (c *blackholeConn) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(&c.silent) == 1 {
+		return len(p), nil
+	}
+	return c.Conn.Write(p)
+}
+
+// SetMaxConcurrentStreams changes the number of concurrent streams advertised
+// to the connections opened from now on. Call GoAway to make the clients
+// reconnect and pick up the new limit.
+func (s *Server) SetMaxConcurrentStreams(n uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.MaxConcurrentStreams = n
+}
+
+// fault returns the Fault to apply to a request for the device token, if any.
+func (s *Server) fault(deviceToken string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.faults {
+		if f.DeviceToken != "" && f.DeviceToken != deviceToken {
+			continue
+		}
+		if f.Probability > 0 && rand.Float64() >= f.Probability {
+			continue
+		}
+		fault := f.Fault
+		if f.remaining > 0 {
+			f.remaining--
+			if f.remaining == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return &fault
+	}
+	return nil
+}
+
+// inject applies the Fault to the request. It returns whether the request was
+// fully handled, in which case no response must be written.
+func (s *Server) inject(r *http.Request, conn net.Conn, f *Fault, n *Notification) (handled bool) {
+	if f.Latency != nil {
+		timer := time.NewTimer(f.Latency())
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			s.record(*n)
+			return true
+		}
+	}
+
+	switch f.Action {
+	case FaultGoAway:
+		s.mu.Lock()
+		hs := s.conns[conn]
+		s.mu.Unlock()
+		if hs != nil {
+			_ = hs.Shutdown(context.Background())
+		}
+	case FaultResetStream:
+		s.record(*n)
+		panic(http.ErrAbortHandler)
+	case FaultCloseConnection:
+		s.record(*n)
+		if conn == nil {
+			panic(http.ErrAbortHandler)
+		}
+		_ = conn.Close()
+		return true
+	}
+	n.Reason = f.Reason
+	return false
+}
+
+// slowRead reads the body one chunk at a time, pausing before each read.
+func slowRead(ctx context.Context, body io.Reader, pause time.Duration) ([]byte, error) {
+	var payload []byte
+	chunk := make([]byte, slowReadChunk)
+	for {
+		timer := time.NewTimer(pause)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return payload, ctx.Err()
+		}
+		n, err := body.Read(chunk)
+		payload = append(payload, chunk[:n]...)
+		if err == io.EOF {
+			return payload, nil
+		}
+		if err != nil {
+			return payload, err
+		}
+	}
+}
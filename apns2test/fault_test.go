@@ -0,0 +1,244 @@
+package apns2test_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/apns2test"
+)
+
+func TestFaultShutdownBurst(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	server.InjectFault(apns2test.Fault{Reason: apns.ReasonShutdown, Times: 2})
+
+	client := server.Client(apns2test.NewCertificate())
+	client.RetryPolicy = apns.NewRetryPolicy()
+	client.RetryPolicy.InitialBackoff = time.Millisecond
+	res, err := client.Push(mockNotification())
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if !res.Sent() || 3 != res.Attempts {
+		t.Fatal("Expected notification sent at attempt 3, found:", res.Reason, res.Attempts)
+	}
+	notifications := server.Notifications()
+	if 3 != len(notifications) || apns.ReasonShutdown != notifications[0].Reason || 503 != notifications[1].StatusCode {
+		t.Fatal("Unexpected notifications:", notifications)
+	}
+}
+
+func TestFaultPerTokenTooManyRequests(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	throttled := strings.Repeat("ab", 32)
+	server.InjectFault(apns2test.Fault{DeviceToken: throttled, Reason: apns.ReasonTooManyRequests})
+
+	client := server.Client(apns2test.NewCertificate())
+	for i := 0; i < 3; i++ {
+		n := mockNotification()
+		n.DeviceToken = throttled
+		res, _ := client.Push(n)
+		if apns.ReasonTooManyRequests != res.Reason || 429 != res.StatusCode {
+			t.Fatal("Expected:", apns.ReasonTooManyRequests, " found:", res.StatusCode, res.Reason)
+		}
+	}
+	if res, _ := client.Push(mockNotification()); !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", res.Reason)
+	}
+
+	server.ClearFaults()
+	n := mockNotification()
+	n.DeviceToken = throttled
+	if res, _ := client.Push(n); !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", res.Reason)
+	}
+}
+
+func TestFaultLatency(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	server.InjectFault(apns2test.Fault{Latency: apns2test.FixedLatency(time.Second), Times: 1})
+
+	client := server.Client(apns2test.NewCertificate())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.PushWithContext(ctx, mockNotification()); !errors.Is(err, apns.ErrTimeout) {
+		t.Fatal("Expected:", apns.ErrTimeout, " found:", err)
+	}
+
+	server.InjectFault(apns2test.Fault{Latency: apns2test.UniformLatency(20*time.Millisecond, 40*time.Millisecond), Times: 1})
+	start := time.Now()
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatal("Expected latency of at least 20ms, found:", elapsed)
+	}
+}
+
+func TestLatencyDistributions(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := apns2test.UniformLatency(10, 20)(); d < 10 || d >= 20 {
+			t.Fatal("Expected latency in [10, 20), found:", d)
+		}
+		if d := apns2test.ExponentialLatency(10)(); d < 0 {
+			t.Fatal("Expected positive latency, found:", d)
+		}
+	}
+	if d := apns2test.FixedLatency(10)(); 10 != d {
+		t.Fatal("Expected:", 10, " found:", d)
+	}
+}
+
+func TestFaultGoAway(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	server.InjectFault(apns2test.Fault{Action: apns2test.FaultGoAway, Times: 1})
+
+	client := server.Client(apns2test.NewCertificate())
+	for i := 0; i < 2; i++ {
+		if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+			t.Fatal("Expected notification to be sent, found:", err)
+		}
+	}
+	if 2 != server.Connections() {
+		t.Fatal("Expected:", 2, " found:", server.Connections())
+	}
+
+	server.GoAway()
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	if 3 != server.Connections() {
+		t.Fatal("Expected:", 3, " found:", server.Connections())
+	}
+}
+
+func TestFaultResetStream(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	server.InjectFault(apns2test.Fault{Action: apns2test.FaultResetStream, Times: 1})
+
+	client := server.Client(apns2test.NewCertificate())
+	if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrStreamReset) {
+		t.Fatal("Expected:", apns.ErrStreamReset, " found:", err)
+	}
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	notifications := server.Notifications()
+	if 2 != len(notifications) || 0 != notifications[0].StatusCode {
+		t.Fatal("Unexpected notifications:", notifications)
+	}
+	if 1 != server.Connections() {
+		t.Fatal("Expected:", 1, " found:", server.Connections())
+	}
+}
+
+func TestFaultCloseConnection(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	server.InjectFault(apns2test.Fault{Action: apns2test.FaultCloseConnection, Times: 1})
+
+	client := server.Client(apns2test.NewCertificate())
+	if _, err := client.Push(mockNotification()); err == nil {
+		t.Fatal("Expected error, found nil")
+	}
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	if 2 != server.Connections() {
+		t.Fatal("Expected:", 2, " found:", server.Connections())
+	}
+
+	server.CloseConnections()
+	client.RetryPolicy = apns.NewRetryPolicy()
+	client.RetryPolicy.InitialBackoff = time.Millisecond
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+}
+
+func TestFaultSlowRead(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	server.InjectFault(apns2test.Fault{SlowRead: 5 * time.Millisecond})
+
+	n := mockNotification()
+	n.Payload = []byte(`{"pad":"` + strings.Repeat("x", 2000) + `"}`)
+	start := time.Now()
+	if res, err := server.Client(apns2test.NewCertificate()).Push(n); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatal("Expected a slow read, found:", elapsed)
+	}
+	if string(n.Payload.([]byte)) != string(server.Notifications()[0].Payload) {
+		t.Fatal("Expected the whole payload to be read")
+	}
+}
+
+func TestMaxConcurrentStreams(t *testing.T) {
+	server := apns2test.NewUnstartedServer()
+	server.MaxConcurrentStreams = 1
+	server.Start()
+	defer server.Close()
+	server.InjectFault(apns2test.Fault{Latency: apns2test.FixedLatency(50 * time.Millisecond)})
+
+	client := server.Client(apns2test.NewCertificate())
+	pushConcurrently := func() {
+		// A first push makes the client learn the limit from the SETTINGS
+		// frame of the server.
+		if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+			t.Fatal("Expected notification to be sent, found:", err)
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+					t.Error("Expected notification to be sent, found:", err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	pushConcurrently()
+	if 3 != server.Connections() {
+		t.Fatal("Expected:", 3, " found:", server.Connections())
+	}
+
+	server.SetMaxConcurrentStreams(10)
+	server.GoAway()
+	pushConcurrently()
+	if 4 != server.Connections() {
+		t.Fatal("Expected:", 4, " found:", server.Connections())
+	}
+}
+
+func TestBlackhole(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	client := server.Client(apns2test.NewCertificate())
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+
+	server.Blackhole()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.PushWithContext(ctx, mockNotification()); !errors.Is(err, apns.ErrTimeout) {
+		t.Fatal("Expected:", apns.ErrTimeout, " found:", err)
+	}
+	if 2 != len(server.Notifications()) {
+		t.Fatal("Expected the request to be received, found:", len(server.Notifications()))
+	}
+}
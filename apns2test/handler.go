@@ -0,0 +1,192 @@
+package apns2test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+// ReasonStatus maps every apns2 Reason constant to the HTTP status code APNs
+// answers it with.
+var ReasonStatus = map[string]int{
+	apns.ReasonBadCollapseID:               http.StatusBadRequest,
+	apns.ReasonBadDeviceToken:              http.StatusBadRequest,
+	apns.ReasonBadExpirationDate:           http.StatusBadRequest,
+	apns.ReasonBadMessageID:                http.StatusBadRequest,
+	apns.ReasonBadPriority:                 http.StatusBadRequest,
+	apns.ReasonBadTopic:                    http.StatusBadRequest,
+	apns.ReasonDeviceTokenNotForTopic:      http.StatusBadRequest,
+	apns.ReasonDuplicateHeaders:            http.StatusBadRequest,
+	apns.ReasonIdleTimeout:                 http.StatusBadRequest,
+	apns.ReasonMissingDeviceToken:          http.StatusBadRequest,
+	apns.ReasonMissingTopic:                http.StatusBadRequest,
+	apns.ReasonPayloadEmpty:                http.StatusBadRequest,
+	apns.ReasonTopicDisallowed:             http.StatusBadRequest,
+	apns.ReasonBadCertificate:              http.StatusForbidden,
+	apns.ReasonBadCertificateEnvironment:   http.StatusForbidden,
+	apns.ReasonExpiredProviderToken:        http.StatusForbidden,
+	apns.ReasonForbidden:                   http.StatusForbidden,
+	apns.ReasonInvalidProviderToken:        http.StatusForbidden,
+	apns.ReasonMissingProviderToken:        http.StatusForbidden,
+	apns.ReasonBadPath:                     http.StatusNotFound,
+	apns.ReasonMethodNotAllowed:            http.StatusMethodNotAllowed,
+	apns.ReasonUnregistered:                http.StatusGone,
+	apns.ReasonPayloadTooLarge:             http.StatusRequestEntityTooLarge,
+	apns.ReasonTooManyProviderTokenUpdates: http.StatusTooManyRequests,
+	apns.ReasonTooManyRequests:             http.StatusTooManyRequests,
+	apns.ReasonInternalServerError:         http.StatusInternalServerError,
+	apns.ReasonServiceUnavailable:          http.StatusServiceUnavailable,
+	apns.ReasonShutdown:                    http.StatusServiceUnavailable,
+}
+
+const devicePath = "/3/device/"
+
+var (
+	validPriorities = map[string]bool{"1": true, "5": true, "10": true}
+	validPushTypes  = map[apns.EPushType]bool{
+		apns.PushTypeAlert:        true,
+		apns.PushTypeBackground:   true,
+		apns.PushTypeVOIP:         true,
+		apns.PushTypeComplication: true,
+		apns.PushTypeFileProvider: true,
+		apns.PushTypeMDM:          true,
+	}
+)
+
+// ServeHTTP handles a notification request the way APNs does. Faults
+// affecting the connection are ignored, as it is not known to the Server.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handle(w, r, nil)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request, conn net.Conn) {
+	n := Notification{
+		DeviceToken: strings.TrimPrefix(r.URL.Path, devicePath),
+		ApnsID:      r.Header.Get("apns-id"),
+		Topic:       r.Header.Get("apns-topic"),
+		CollapseID:  r.Header.Get("apns-collapse-id"),
+		PushType:    r.Header.Get("apns-push-type"),
+		Header:      r.Header,
+	}
+	if n.ApnsID == "" {
+		n.ApnsID = apns.NewApnsID()
+	}
+	n.Priority, _ = strconv.Atoi(r.Header.Get("apns-priority"))
+	if expiration, err := strconv.ParseInt(r.Header.Get("apns-expiration"), 10, 64); err == nil && expiration > 0 {
+		n.Expiration = time.Unix(expiration, 0)
+	}
+	fault := s.fault(n.DeviceToken)
+	if fault != nil && fault.SlowRead > 0 {
+		n.Payload, _ = slowRead(r.Context(), r.Body, fault.SlowRead)
+	} else {
+		n.Payload, _ = ioutil.ReadAll(r.Body)
+	}
+
+	if fault != nil && s.inject(r, conn, fault, &n) {
+		return
+	}
+	if n.Reason == "" {
+		n.Reason = s.check(r, &n)
+	}
+	if n.Reason == "" {
+		s.mu.Lock()
+		n.Reason = s.rejections[n.DeviceToken]
+		s.mu.Unlock()
+	}
+	respond(w, &n)
+	if strings.HasPrefix(r.URL.Path, devicePath) && r.Method == http.MethodPost {
+		s.record(n)
+	}
+}
+
+// check returns the reason the request must be rejected with, if any.
+func (s *Server) check(r *http.Request, n *Notification) string {
+	if !strings.HasPrefix(r.URL.Path, devicePath) {
+		return apns.ReasonBadPath
+	}
+	if r.Method != http.MethodPost {
+		return apns.ReasonMethodNotAllowed
+	}
+	for _, values := range r.Header {
+		if len(values) > 1 {
+			return apns.ReasonDuplicateHeaders
+		}
+	}
+
+	tokenAuth, reason := s.authenticate(r)
+	if reason != "" {
+		return reason
+	}
+
+	switch _, err := hex.DecodeString(n.DeviceToken); {
+	case n.DeviceToken == "":
+		return apns.ReasonMissingDeviceToken
+	case err != nil, len(n.DeviceToken) < apns.MinDeviceTokenLength, len(n.DeviceToken) > apns.MaxDeviceTokenLength:
+		return apns.ReasonBadDeviceToken
+	}
+	if n.Topic == "" && tokenAuth {
+		return apns.ReasonMissingTopic
+	}
+	if r.Header.Get("apns-id") != "" && !apns.ValidApnsID(n.ApnsID) {
+		return apns.ReasonBadMessageID
+	}
+	if len(n.CollapseID) > apns.MaxCollapseIDSize {
+		return apns.ReasonBadCollapseID
+	}
+	if priority := r.Header.Get("apns-priority"); priority != "" && !validPriorities[priority] {
+		return apns.ReasonBadPriority
+	}
+	if expiration := r.Header.Get("apns-expiration"); expiration != "" {
+		if _, err := strconv.ParseInt(expiration, 10, 64); err != nil {
+			return apns.ReasonBadExpirationDate
+		}
+	}
+	if n.PushType != "" && !validPushTypes[apns.EPushType(n.PushType)] {
+		return apns.ReasonBadTopic
+	}
+	if n.PushType == string(apns.PushTypeVOIP) && n.Topic != "" && !strings.HasSuffix(n.Topic, ".voip") {
+		return apns.ReasonTopicDisallowed
+	}
+
+	maxSize := apns.MaxPayloadSize
+	if n.PushType == string(apns.PushTypeVOIP) {
+		maxSize = apns.MaxVOIPPayloadSize
+	}
+	switch {
+	case len(n.Payload) == 0:
+		return apns.ReasonPayloadEmpty
+	case len(n.Payload) > maxSize:
+		return apns.ReasonPayloadTooLarge
+	}
+	return ""
+}
+
+// respond writes the response for the notification, filling in its
+// StatusCode.
+func respond(w http.ResponseWriter, n *Notification) {
+	w.Header().Set("apns-id", n.ApnsID)
+	if n.Reason == "" {
+		n.StatusCode = apns.StatusSent
+		w.WriteHeader(n.StatusCode)
+		return
+	}
+
+	n.StatusCode = ReasonStatus[n.Reason]
+	if n.StatusCode == 0 {
+		n.StatusCode = http.StatusBadRequest
+	}
+	body := map[string]interface{}{"reason": n.Reason}
+	if n.StatusCode == http.StatusGone {
+		body["timestamp"] = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(n.StatusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
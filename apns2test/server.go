@@ -0,0 +1,257 @@
+// Package apns2test provides an in-process APNs server for integration tests.
+//
+// The Server speaks HTTP/2 over TLS like the real APNs: it routes
+// /3/device/{token}, enforces the request headers, the payload size and the
+// provider authentication, answers with the JSON error bodies documented by
+// Apple and records every notification it receives.
+package apns2test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/token"
+	"golang.org/x/net/http2"
+)
+
+// DefaultMaxConcurrentStreams is the number of concurrent streams advertised
+// by the Server when MaxConcurrentStreams is zero.
+const DefaultMaxConcurrentStreams = 1000
+
+// Notification is a request received by the Server, along with the response
+// it was answered with.
+type Notification struct {
+	DeviceToken string
+	ApnsID      string
+	Topic       string
+	CollapseID  string
+	PushType    string
+	Priority    int
+	Expiration  time.Time
+	Header      http.Header
+	Payload     []byte
+
+	// The response sent by the Server. StatusCode is zero if the stream was
+	// reset or the connection closed by an injected Fault.
+	StatusCode int
+	Reason     string
+}
+
+// Sent returns whether the notification was accepted by the Server.
+func (n Notification) Sent() bool {
+	return n.StatusCode == apns.StatusSent
+}
+
+// Server is an APNs server listening on a local address. Its exported fields
+// must be set before Start is called.
+type Server struct {
+	// URL is the base URL of the Server, of the form https://127.0.0.1:port.
+	URL string
+
+	// Certificate is the self-signed certificate presented by the Server.
+	Certificate *x509.Certificate
+
+	// MaxConcurrentStreams is the number of concurrent streams advertised to
+	// every connection. If zero, DefaultMaxConcurrentStreams is used. Use
+	// SetMaxConcurrentStreams to change it once the Server is started.
+	MaxConcurrentStreams uint32
+
+	listener  net.Listener
+	tlsConfig *tls.Config
+	wg        sync.WaitGroup
+
+	mu            sync.Mutex
+	closed        bool
+	accepted      int
+	conns         map[net.Conn]*http.Server
+	keys          map[string]trustedKey
+	certificates  map[string]bool
+	rejections    map[string]string
+	faults        []*faultState
+	notifications []Notification
+}
+
+// NewServer starts and returns a new Server. The caller should call Close when
+// finished, to shut it down.
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a new Server listening on a local port but not
+// yet serving requests, so that its configuration can be changed.
+func NewUnstartedServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("apns2test: failed to listen on a port: %v", err))
+	}
+	certificate, err := newServerCertificate()
+	if err != nil {
+		panic(fmt.Sprintf("apns2test: failed to generate the server certificate: %v", err))
+	}
+	return &Server{
+		URL:         "https://" + listener.Addr().String(),
+		Certificate: certificate.Leaf,
+		listener:    listener,
+		tlsConfig: &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			ClientAuth:   tls.RequestClientCert,
+			NextProtos:   []string{http2.NextProtoTLS},
+			MinVersion:   tls.VersionTLS12,
+		},
+		conns:        map[net.Conn]*http.Server{},
+		keys:         map[string]trustedKey{},
+		certificates: map[string]bool{},
+		rejections:   map[string]string{},
+	}
+}
+
+// Start starts serving requests.
+func (s *Server) Start() {
+	if s.MaxConcurrentStreams == 0 {
+		s.MaxConcurrentStreams = DefaultMaxConcurrentStreams
+	}
+	s.wg.Add(1)
+	go s.accept()
+}
+
+// Close stops the Server, closing every open connection, and waits for the
+// outstanding requests to complete.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	_ = s.listener.Close()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Client returns a Client pointed at the Server, authenticating with the
+// certificate. The certificate is trusted by the Server.
+func (s *Server) Client(certificate tls.Certificate) *apns.Client {
+	s.TrustCertificate(certificate)
+	client := apns.NewClient(certificate)
+	client.Host = s.URL
+	client.HTTPClient.Transport.(*http2.Transport).TLSClientConfig.RootCAs = s.certPool()
+	return client
+}
+
+// TokenClient returns a Client pointed at the Server, authenticating with the
+// token. The token is trusted by the Server.
+func (s *Server) TokenClient(t *token.Token) *apns.Client {
+	s.TrustToken(t)
+	client := apns.NewTokenClient(t)
+	client.Host = s.URL
+	client.HTTPClient.Transport.(*http2.Transport).TLSClientConfig = &tls.Config{
+		RootCAs:    s.certPool(),
+		MinVersion: tls.VersionTLS12,
+	}
+	return client
+}
+
+// RejectDeviceToken makes the Server reject every notification sent to the
+// device token with the reason, which should be one of the apns2 Reason
+// constants. Notifications rejected as Unregistered carry the current time as
+// timestamp.
+func (s *Server) RejectDeviceToken(deviceToken, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejections[deviceToken] = reason
+}
+
+// Notifications returns the notifications received so far, in order of
+// arrival.
+func (s *Server) Notifications() []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Notification(nil), s.notifications...)
+}
+
+// Connections returns the number of connections established with the Server
+// since it was started.
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accepted
+}
+
+// Reset forgets the notifications received so far.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications = nil
+}
+
+func (s *Server) certPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate)
+	return pool
+}
+
+func (s *Server) record(n Notification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications = append(s.notifications, n)
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+// serve runs an HTTP/2 server on a single connection. Each connection has its
+// own http.Server, so that it can be shut down gracefully with a GOAWAY frame
+// independently of the others.
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	conn = &blackholeConn{Conn: conn}
+	tlsConn := tls.Server(conn, s.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	s.mu.Lock()
+	h2 := &http2.Server{MaxConcurrentStreams: s.MaxConcurrentStreams}
+	s.mu.Unlock()
+	hs := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handle(w, r, conn)
+	})}
+	if err := http2.ConfigureServer(hs, h2); err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = conn.Close()
+		return
+	}
+	s.conns[conn] = hs
+	s.accepted++
+	s.mu.Unlock()
+
+	h2.ServeConn(tlsConn, &http2.ServeConnOpts{BaseConfig: hs})
+
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	_ = conn.Close()
+}
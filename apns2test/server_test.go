@@ -0,0 +1,220 @@
+package apns2test_test
+
+import (
+	"crypto/x509"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/apns2test"
+	"golang.org/x/net/http2"
+)
+
+const deviceToken = "11aa01229f15f0f0c52029d8cf8cd0aeaf2365fe4cebc4af26cd6d76b7919ef7"
+
+func mockNotification() *apns.Notification {
+	n := &apns.Notification{}
+	n.DeviceToken = deviceToken
+	n.Topic = "com.apns2test"
+	n.Payload = []byte(`{"aps":{"alert":"Hello!"}}`)
+	return n
+}
+
+func TestCertificateClient(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+
+	client := server.Client(apns2test.NewCertificate())
+	n := mockNotification()
+	n.ApnsID = "84DB694F-464F-49BD-960A-D6DB028335C9"
+	n.CollapseID = "game1.start.identifier"
+	n.Priority = apns.PriorityLow
+	n.PushType = apns.PushTypeAlert
+	n.Expiration = time.Unix(1600000000, 0)
+	res, err := client.Push(n)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if !res.Sent() || n.ApnsID != res.ApnsID {
+		t.Fatal("Expected notification to be sent, found:", res.StatusCode, res.Reason)
+	}
+
+	notifications := server.Notifications()
+	if 1 != len(notifications) {
+		t.Fatal("Expected:", 1, " found:", len(notifications))
+	}
+	received := notifications[0]
+	if !received.Sent() || deviceToken != received.DeviceToken || n.ApnsID != received.ApnsID ||
+		n.Topic != received.Topic || n.CollapseID != received.CollapseID || "alert" != received.PushType ||
+		apns.PriorityLow != received.Priority || !n.Expiration.Equal(received.Expiration) {
+		t.Fatal("Unexpected notification:", received)
+	}
+	if string(n.Payload.([]byte)) != string(received.Payload) {
+		t.Fatal("Expected:", string(n.Payload.([]byte)), " found:", string(received.Payload))
+	}
+
+	server.Reset()
+	if 0 != len(server.Notifications()) {
+		t.Fatal("Expected no notifications after Reset")
+	}
+}
+
+func TestCertificateClientWithoutTopic(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+
+	n := mockNotification()
+	n.Topic = ""
+	res, err := server.Client(apns2test.NewCertificate()).Push(n)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", res.Reason)
+	}
+	if 36 != len(res.ApnsID) {
+		t.Fatal("Expected a generated apns-id, found:", res.ApnsID)
+	}
+}
+
+func TestUntrustedCertificate(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+
+	client := apns.NewClient(apns2test.NewCertificate())
+	client.Host = server.URL
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate)
+	client.HTTPClient.Transport.(*http2.Transport).TLSClientConfig.RootCAs = roots
+	res, err := client.Push(mockNotification())
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if apns.ReasonBadCertificate != res.Reason {
+		t.Fatal("Expected:", apns.ReasonBadCertificate, " found:", res.Reason)
+	}
+}
+
+func TestTokenClient(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+
+	res, err := server.TokenClient(apns2test.NewToken()).Push(mockNotification())
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", res.Reason)
+	}
+	received := server.Notifications()[0]
+	if !strings.HasPrefix(received.Header.Get("authorization"), "bearer ") {
+		t.Fatal("Expected bearer authorization, found:", received.Header.Get("authorization"))
+	}
+}
+
+func TestTokenClientErrors(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+
+	client := server.TokenClient(apns2test.NewToken())
+	n := mockNotification()
+	n.Topic = ""
+	res, _ := client.Push(n)
+	if apns.ReasonMissingTopic != res.Reason {
+		t.Fatal("Expected:", apns.ReasonMissingTopic, " found:", res.Reason)
+	}
+
+	untrusted := apns.NewTokenClient(apns2test.NewToken())
+	untrusted.Host = server.URL
+	untrusted.HTTPClient = client.HTTPClient
+	res, _ = untrusted.Push(mockNotification())
+	if apns.ReasonInvalidProviderToken != res.Reason || http.StatusForbidden != res.StatusCode {
+		t.Fatal("Expected:", apns.ReasonInvalidProviderToken, " found:", res.StatusCode, res.Reason)
+	}
+}
+
+func TestExpiredToken(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+
+	token := apns2test.NewToken()
+	client := server.TokenClient(token)
+	bearer, err := (&jwt.Token{
+		Header: map[string]interface{}{"alg": "ES256", "kid": token.KeyID},
+		Claims: jwt.MapClaims{
+			"iss": token.TeamID,
+			"iat": time.Now().Add(-2 * time.Hour).Unix(),
+		},
+		Method: jwt.SigningMethodES256,
+	}).SignedString(token.AuthKey)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	token.Bearer = bearer
+	token.IssuedAt = time.Now().Unix()
+
+	res, _ := client.Push(mockNotification())
+	if apns.ReasonExpiredProviderToken != res.Reason {
+		t.Fatal("Expected:", apns.ReasonExpiredProviderToken, " found:", res.Reason)
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+
+	client := server.Client(apns2test.NewCertificate())
+	scenarios := []struct {
+		modify func(n *apns.Notification)
+		reason string
+	}{
+		{func(n *apns.Notification) { n.DeviceToken = "zz" }, apns.ReasonBadDeviceToken},
+		{func(n *apns.Notification) { n.ApnsID = "bad" }, apns.ReasonBadMessageID},
+		{func(n *apns.Notification) { n.CollapseID = strings.Repeat("c", 65) }, apns.ReasonBadCollapseID},
+		{func(n *apns.Notification) { n.Priority = 7 }, apns.ReasonBadPriority},
+		{func(n *apns.Notification) { n.PushType = "unknown" }, apns.ReasonBadTopic},
+		{func(n *apns.Notification) { n.PushType = apns.PushTypeVOIP }, apns.ReasonTopicDisallowed},
+		{func(n *apns.Notification) {
+			n.Payload = []byte(`{"pad":"` + strings.Repeat("x", apns.MaxPayloadSize) + `"}`)
+		}, apns.ReasonPayloadTooLarge},
+	}
+	for _, scenario := range scenarios {
+		n := mockNotification()
+		scenario.modify(n)
+		res, err := client.Push(n)
+		if err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+		if scenario.reason != res.Reason || apns2test.ReasonStatus[scenario.reason] != res.StatusCode {
+			t.Fatal("Expected:", scenario.reason, " found:", res.StatusCode, res.Reason)
+		}
+	}
+}
+
+func TestRejectDeviceToken(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+
+	client := server.Client(apns2test.NewCertificate())
+	for reason, status := range apns2test.ReasonStatus {
+		server.RejectDeviceToken(deviceToken, reason)
+		res, err := client.Push(mockNotification())
+		if err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+		if reason != res.Reason || status != res.StatusCode {
+			t.Fatal("Expected:", status, reason, " found:", res.StatusCode, res.Reason)
+		}
+		if (reason == apns.ReasonUnregistered) == res.Timestamp.IsZero() {
+			t.Fatal("Unexpected timestamp for", reason, ":", res.Timestamp)
+		}
+	}
+
+	notifications := server.Notifications()
+	if len(apns2test.ReasonStatus) != len(notifications) || notifications[0].Sent() {
+		t.Fatal("Expected", len(apns2test.ReasonStatus), "rejected notifications, found:", len(notifications))
+	}
+}
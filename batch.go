@@ -0,0 +1,132 @@
+package apns2
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultPushConcurrency is the number of notifications sent at the same time
+// by PushMulti and PushStream when no concurrency is configured.
+const DefaultPushConcurrency = 64
+
+// PushResult pairs a Notification with the outcome of its push.
+type PushResult struct {
+	// The Notification which was pushed.
+	Notification *Notification
+
+	// The Response returned by APNs, if any.
+	Response *Response
+
+	// The error which occurred while pushing the Notification, if any. If the
+	// context was done before the Notification was sent, this is the context
+	// error.
+	Err error
+}
+
+// PushMultiOptions configures PushMulti and PushStream.
+type PushMultiOptions struct {
+	// Concurrency is the maximum number of notifications sent at the same
+	// time. If zero, DefaultPushConcurrency is used.
+	Concurrency int
+}
+
+func (o *PushMultiOptions) concurrency() int {
+	if o == nil || o.Concurrency <= 0 {
+		return DefaultPushConcurrency
+	}
+	return o.Concurrency
+}
+
+// PushMulti sends the notifications to the APNs gateway, running up to
+// opts.Concurrency pushes at the same time, and waits for all of them to
+// complete. The returned results are in the same order as notifications.
+//
+// When the context is done, the notifications which were not sent yet are not
+// sent at all and their result carries the context error. opts can be nil.
+func (c *Client) PushMulti(ctx Context, notifications []*Notification, opts *PushMultiOptions) []PushResult {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	results := make([]PushResult, len(notifications))
+	workers := opts.concurrency()
+	if workers > len(notifications) {
+		workers = len(notifications)
+	}
+
+	next := int64(-1)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(notifications) {
+					return
+				}
+				results[i] = pushResult(ctx, c, notifications[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// PushStream sends the notifications received from the input channel to the
+// APNs gateway, running up to opts.Concurrency pushes at the same time. The
+// results are delivered on the returned channel in completion order, which may
+// differ from the input order.
+//
+// The returned channel is closed once the input channel is closed and all its
+// notifications were pushed, or once the context is done and the in-flight
+// pushes have completed. Once the context is done, results which are not
+// received are discarded. opts can be nil.
+func (c *Client) PushStream(ctx Context, notifications <-chan *Notification, opts *PushMultiOptions) <-chan PushResult {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	workers := opts.concurrency()
+	results := make(chan PushResult, workers)
+
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case n, ok := <-notifications:
+					if !ok {
+						return
+					}
+					result := pushResult(ctx, c, n)
+					select {
+					case results <- result:
+					default:
+						select {
+						case results <- result:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func pushResult(ctx Context, p Pusher, n *Notification) PushResult {
+	if err := ctx.Err(); err != nil {
+		return PushResult{Notification: n, Err: err}
+	}
+	res, err := p.PushWithContext(ctx, n)
+	return PushResult{Notification: n, Response: res, Err: err}
+}
@@ -0,0 +1,188 @@
+package apns2_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+func mockNotifications(count int) []*apns.Notification {
+	notifications := make([]*apns.Notification, count)
+	for i := range notifications {
+		n := mockNotification()
+		n.DeviceToken = fmt.Sprintf("%064x", i)
+		notifications[i] = n
+	}
+	return notifications
+}
+
+func TestPushMultiPreservesOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, "/3/device/")
+		w.Header().Set("apns-id", token)
+		if strings.HasSuffix(token, "3") {
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte(`{"reason":"Unregistered"}`))
+		}
+	}))
+	defer server.Close()
+
+	notifications := mockNotifications(50)
+	results := mockClient(server.URL).PushMulti(context.Background(), notifications, &apns.PushMultiOptions{Concurrency: 8})
+	if len(notifications) != len(results) {
+		t.Fatal("Expected:", len(notifications), " found:", len(results))
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatal("Expected no error, found:", result.Err)
+		}
+		if notifications[i] != result.Notification {
+			t.Fatal("Expected result", i, "to pair with its notification")
+		}
+		if notifications[i].DeviceToken != result.Response.ApnsID {
+			t.Fatal("Expected:", notifications[i].DeviceToken, " found:", result.Response.ApnsID)
+		}
+		if strings.HasSuffix(notifications[i].DeviceToken, "3") == result.Response.Sent() {
+			t.Fatal("Unexpected status", result.Response.StatusCode, "for", notifications[i].DeviceToken)
+		}
+	}
+}
+
+func TestPushMultiConcurrencyLimit(t *testing.T) {
+	var inFlight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer server.Close()
+
+	results := mockClient(server.URL).PushMulti(nil, mockNotifications(30), &apns.PushMultiOptions{Concurrency: 3})
+	if 30 != len(results) {
+		t.Fatal("Expected:", 30, " found:", len(results))
+	}
+	if atomic.LoadInt32(&peak) > 3 {
+		t.Fatal("Expected at most", 3, "concurrent pushes, found:", peak)
+	}
+}
+
+func TestPushMultiCancelled(t *testing.T) {
+	var requests int32
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 2 {
+			cancel()
+		}
+	}))
+	defer server.Close()
+
+	results := mockClient(server.URL).PushMulti(ctx, mockNotifications(20), &apns.PushMultiOptions{Concurrency: 1})
+	if 20 != len(results) {
+		t.Fatal("Expected:", 20, " found:", len(results))
+	}
+	if atomic.LoadInt32(&requests) > 3 {
+		t.Fatal("Expected pushes to stop after cancellation, found:", requests)
+	}
+	last := results[len(results)-1]
+	if !errors.Is(last.Err, context.Canceled) || last.Response != nil {
+		t.Fatal("Expected:", context.Canceled, " found:", last.Err)
+	}
+}
+
+func TestPushMultiEmpty(t *testing.T) {
+	results := mockClient("").PushMulti(context.Background(), nil, nil)
+	if 0 != len(results) {
+		t.Fatal("Expected:", 0, " found:", len(results))
+	}
+}
+
+func TestPushStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("apns-id", strings.TrimPrefix(r.URL.Path, "/3/device/"))
+	}))
+	defer server.Close()
+
+	notifications := make(chan *apns.Notification)
+	go func() {
+		for _, n := range mockNotifications(25) {
+			notifications <- n
+		}
+		close(notifications)
+	}()
+
+	count := 0
+	for result := range mockClient(server.URL).PushStream(context.Background(), notifications, &apns.PushMultiOptions{Concurrency: 4}) {
+		if result.Err != nil {
+			t.Fatal("Expected no error, found:", result.Err)
+		}
+		if result.Notification.DeviceToken != result.Response.ApnsID {
+			t.Fatal("Expected:", result.Notification.DeviceToken, " found:", result.Response.ApnsID)
+		}
+		count++
+	}
+	if 25 != count {
+		t.Fatal("Expected:", 25, " found:", count)
+	}
+}
+
+func TestPushStreamCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	notifications := make(chan *apns.Notification)
+	results := mockClient(server.URL).PushStream(ctx, notifications, nil)
+	notifications <- mockNotification()
+	<-results
+	cancel()
+
+	select {
+	case _, ok := <-results:
+		if ok {
+			t.Fatal("Expected results channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected results channel to be closed after cancellation")
+	}
+}
+
+func TestPushStreamCancelledUnreadResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	notifications := make(chan *apns.Notification, 3)
+	for _, n := range mockNotifications(3) {
+		notifications <- n
+	}
+	close(notifications)
+	results := mockClient(server.URL).PushStream(ctx, notifications, &apns.PushMultiOptions{Concurrency: 1})
+	for len(results) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	// The worker blocked on the full results channel gives up its result.
+	time.Sleep(50 * time.Millisecond)
+	count := 0
+	for range results {
+		count++
+	}
+	if 1 != count {
+		t.Fatal("Expected:", 1, " found:", count)
+	}
+}
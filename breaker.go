@@ -0,0 +1,248 @@
+package apns2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen matches, with errors.Is, the CircuitOpenError returned while
+// the CircuitBreaker of the Client is open.
+var ErrCircuitOpen = errors.New("apns2: circuit breaker open")
+
+// CircuitOpenError is returned, without contacting APNs, for the requests
+// rejected by an open CircuitBreaker.
+type CircuitOpenError struct {
+	// RetryAfter is the time left before the CircuitBreaker lets trial
+	// requests through. It is zero when the trial requests are in flight.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", ErrCircuitOpen, e.RetryAfter)
+}
+
+// Is reports whether target is ErrCircuitOpen.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects every request.
+	CircuitOpen
+	// CircuitHalfOpen lets a few trial requests through, closing the circuit
+	// if they all succeed and opening it again otherwise.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitBreaker stops a Client from sending requests to APNs during sustained
+// outages. It opens when the ratio of failed requests over a window reaches
+// FailureRatio, rejects the requests with a CircuitOpenError for OpenTimeout,
+// then lets HalfOpenRequests trial requests through. The exported fields must
+// not be changed once the CircuitBreaker is in use.
+type CircuitBreaker struct {
+	// FailureRatio is the ratio of failed requests, between 0 and 1, which
+	// opens the circuit.
+	FailureRatio float64
+
+	// MinRequests is the number of requests a window must count before the
+	// circuit can open.
+	MinRequests int
+
+	// Window is the interval over which requests are counted. Counts are
+	// reset at the end of every window.
+	Window time.Duration
+
+	// OpenTimeout is how long the circuit stays open before trial requests
+	// are let through.
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests is the number of trial requests which must succeed to
+	// close the circuit. Values lower than 1 are treated as 1.
+	HalfOpenRequests int
+
+	// IsFailure reports whether the outcome of a request counts as a failure.
+	// If nil, transport errors other than context cancellation and 5xx
+	// responses are failures. Requests cancelled by their context which are
+	// not failures are not counted, and free their slot if they were trials.
+	IsFailure func(res *Response, err error) bool
+
+	// OnStateChange, if set, is called after every state change. It is
+	// called synchronously by the goroutine pushing the notification, except
+	// for the change from open to half-open, which happens after OpenTimeout
+	// even if no notification is pushed and may be reported by a timer
+	// goroutine.
+	OnStateChange func(from, to CircuitState)
+
+	mu          sync.Mutex
+	state       CircuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	generation  uint64
+	trials      int
+	successes   int
+}
+
+// NewCircuitBreaker returns a CircuitBreaker with sensible defaults: it opens
+// when half of at least 20 requests over 10s fail, and lets a trial request
+// through after 30s.
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureRatio:     0.5,
+		MinRequests:      20,
+		Window:           10 * time.Second,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenRequests: 1,
+	}
+}
+
+// State returns the current state of the CircuitBreaker.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// allow returns a CircuitOpenError if the request must not be sent. Otherwise
+// it returns the generation of the state letting the request through, to be
+// passed to record.
+func (b *CircuitBreaker) allow() (uint64, error) {
+	b.halfOpen()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		return 0, &CircuitOpenError{RetryAfter: b.OpenTimeout - time.Since(b.openedAt)}
+	case CircuitHalfOpen:
+		if b.trials >= b.halfOpenRequests() {
+			return 0, &CircuitOpenError{}
+		}
+		b.trials++
+	}
+	return b.generation, nil
+}
+
+// halfOpen lets the trial requests through once the circuit has been open for
+// OpenTimeout. It is called by the timer armed when the circuit opens, and by
+// allow in case the timer has not fired yet.
+func (b *CircuitBreaker) halfOpen() {
+	b.mu.Lock()
+	if b.state != CircuitOpen || time.Since(b.openedAt) < b.OpenTimeout {
+		b.mu.Unlock()
+		return
+	}
+	b.state, b.trials, b.successes = CircuitHalfOpen, 0, 0
+	b.generation++
+	b.mu.Unlock()
+	b.changed(CircuitOpen, CircuitHalfOpen)
+}
+
+// record counts the outcome of a request let through by allow. Outcomes of
+// requests let through before the last state change are ignored, so only the
+// trial requests can close or open again a half-open circuit.
+func (b *CircuitBreaker) record(generation uint64, res *Response, err error) {
+	failed := b.failed(res, err)
+	cancelled := !failed && res == nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	from, now := b.state, time.Now()
+	switch {
+	case cancelled:
+		b.releaseTrial()
+	case b.state == CircuitHalfOpen:
+		if failed {
+			b.open(now)
+		} else if b.successes++; b.successes >= b.halfOpenRequests() {
+			b.state = CircuitClosed
+			b.generation++
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+	case b.state == CircuitClosed:
+		if now.Sub(b.windowStart) >= b.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.MinRequests && float64(b.failures) >= b.FailureRatio*float64(b.requests) {
+			b.open(now)
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.changed(from, to)
+}
+
+// release frees the slot taken by allow for a request which was not sent.
+func (b *CircuitBreaker) release(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation == b.generation {
+		b.releaseTrial()
+	}
+}
+
+func (b *CircuitBreaker) releaseTrial() {
+	if b.state == CircuitHalfOpen {
+		b.trials--
+	}
+}
+
+func (b *CircuitBreaker) open(now time.Time) {
+	b.state, b.openedAt = CircuitOpen, now
+	b.generation++
+	time.AfterFunc(b.OpenTimeout, b.halfOpen)
+}
+
+func (b *CircuitBreaker) halfOpenRequests() int {
+	if b.HalfOpenRequests < 1 {
+		return 1
+	}
+	return b.HalfOpenRequests
+}
+
+func (b *CircuitBreaker) failed(res *Response, err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(res, err)
+	}
+	if err != nil {
+		return res == nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return res != nil && res.StatusCode >= 500
+}
+
+func (b *CircuitBreaker) changed(from, to CircuitState) {
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(from, to)
+	}
+}
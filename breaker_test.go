@@ -0,0 +1,271 @@
+package apns2_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var unavailable int32 = 1
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&unavailable) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"reason":"ServiceUnavailable"}`))
+		}
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	var changes []string
+	breaker := apns.NewCircuitBreaker()
+	breaker.MinRequests = 4
+	breaker.OpenTimeout = 50 * time.Millisecond
+	breaker.OnStateChange = func(from, to apns.CircuitState) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, from.String()+" -> "+to.String())
+	}
+	client := mockClient(server.URL)
+	client.CircuitBreaker = breaker
+
+	for i := 0; i < 4; i++ {
+		if res, err := client.Push(mockNotification()); err != nil || 503 != res.StatusCode {
+			t.Fatal("Expected:", 503, " found:", err)
+		}
+	}
+	if apns.CircuitOpen != breaker.State() {
+		t.Fatal("Expected:", apns.CircuitOpen, " found:", breaker.State())
+	}
+	_, err := client.Push(mockNotification())
+	var openErr *apns.CircuitOpenError
+	if !errors.Is(err, apns.ErrCircuitOpen) || !errors.As(err, &openErr) || openErr.RetryAfter <= 0 {
+		t.Fatal("Expected:", apns.ErrCircuitOpen, " found:", err)
+	}
+	if 4 != atomic.LoadInt32(&requests) {
+		t.Fatal("Expected:", 4, " found:", atomic.LoadInt32(&requests))
+	}
+
+	// A failed trial opens the circuit again.
+	time.Sleep(breaker.OpenTimeout)
+	if res, err := client.Push(mockNotification()); err != nil || 503 != res.StatusCode {
+		t.Fatal("Expected:", 503, " found:", err)
+	}
+	if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrCircuitOpen) {
+		t.Fatal("Expected:", apns.ErrCircuitOpen, " found:", err)
+	}
+
+	atomic.StoreInt32(&unavailable, 0)
+	time.Sleep(breaker.OpenTimeout)
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	if apns.CircuitClosed != breaker.State() {
+		t.Fatal("Expected:", apns.CircuitClosed, " found:", breaker.State())
+	}
+
+	expected := []string{"closed -> open", "open -> half-open", "half-open -> open", "open -> half-open", "half-open -> closed"}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(expected) != len(changes) {
+		t.Fatal("Expected:", expected, " found:", changes)
+	}
+	for i := range expected {
+		if expected[i] != changes[i] {
+			t.Fatal("Expected:", expected, " found:", changes)
+		}
+	}
+}
+
+func TestCircuitBreakerFailureRatio(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1)%3 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.CircuitBreaker = apns.NewCircuitBreaker()
+	client.CircuitBreaker.MinRequests = 3
+	for i := 0; i < 30; i++ {
+		if _, err := client.Push(mockNotification()); err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+	}
+	if apns.CircuitClosed != client.CircuitBreaker.State() {
+		t.Fatal("Expected:", apns.CircuitClosed, " found:", client.CircuitBreaker.State())
+	}
+}
+
+func TestCircuitBreakerTransportErrors(t *testing.T) {
+	client := mockClient("http://127.0.0.1:1")
+	client.CircuitBreaker = apns.NewCircuitBreaker()
+	client.CircuitBreaker.MinRequests = 2
+	for i := 0; i < 2; i++ {
+		if _, err := client.Push(mockNotification()); err == nil || errors.Is(err, apns.ErrCircuitOpen) {
+			t.Fatal("Expected transport error, found:", err)
+		}
+	}
+	if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrCircuitOpen) {
+		t.Fatal("Expected:", apns.ErrCircuitOpen, " found:", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpenWithoutPushes(t *testing.T) {
+	changes := make(chan apns.CircuitState, 2)
+	client := mockClient("http://127.0.0.1:1")
+	client.CircuitBreaker = apns.NewCircuitBreaker()
+	client.CircuitBreaker.MinRequests = 1
+	client.CircuitBreaker.OpenTimeout = 50 * time.Millisecond
+	client.CircuitBreaker.OnStateChange = func(from, to apns.CircuitState) {
+		changes <- to
+	}
+	if _, err := client.Push(mockNotification()); err == nil {
+		t.Fatal("Expected transport error, found:", err)
+	}
+	if to := <-changes; apns.CircuitOpen != to {
+		t.Fatal("Expected:", apns.CircuitOpen, " found:", to)
+	}
+	select {
+	case to := <-changes:
+		if apns.CircuitHalfOpen != to {
+			t.Fatal("Expected:", apns.CircuitHalfOpen, " found:", to)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the circuit to become half-open without pushes")
+	}
+}
+
+func TestCircuitBreakerCancelledTrial(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := mockClient(server.URL)
+	client.CircuitBreaker = apns.NewCircuitBreaker()
+	client.CircuitBreaker.MinRequests = 1
+	client.CircuitBreaker.OpenTimeout = 50 * time.Millisecond
+	if res, err := client.Push(mockNotification()); err != nil || 503 != res.StatusCode {
+		t.Fatal("Expected:", 503, " found:", err)
+	}
+	time.Sleep(client.CircuitBreaker.OpenTimeout)
+
+	// Trials timing out against a hanging server neither close the circuit
+	// nor hold on to their slot.
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err := client.PushWithContext(ctx, mockNotification())
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("Expected:", context.DeadlineExceeded, " found:", err)
+		}
+		if apns.CircuitHalfOpen != client.CircuitBreaker.State() {
+			t.Fatal("Expected:", apns.CircuitHalfOpen, " found:", client.CircuitBreaker.State())
+		}
+	}
+}
+
+func TestCircuitBreakerIgnoresStaleRequests(t *testing.T) {
+	var requests int32
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			close(started)
+			<-release
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.CircuitBreaker = apns.NewCircuitBreaker()
+	client.CircuitBreaker.MinRequests = 2
+	client.CircuitBreaker.OpenTimeout = 50 * time.Millisecond
+	done := make(chan error)
+	go func() {
+		_, err := client.Push(mockNotification())
+		done <- err
+	}()
+	<-started
+
+	for i := 0; i < 2; i++ {
+		if res, err := client.Push(mockNotification()); err != nil || 503 != res.StatusCode {
+			t.Fatal("Expected:", 503, " found:", err)
+		}
+	}
+	time.Sleep(client.CircuitBreaker.OpenTimeout)
+	if apns.CircuitHalfOpen != client.CircuitBreaker.State() {
+		t.Fatal("Expected:", apns.CircuitHalfOpen, " found:", client.CircuitBreaker.State())
+	}
+
+	// The request sent before the circuit opened is not a trial.
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if apns.CircuitHalfOpen != client.CircuitBreaker.State() {
+		t.Fatal("Expected:", apns.CircuitHalfOpen, " found:", client.CircuitBreaker.State())
+	}
+}
+
+func TestCircuitBreakerRateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.CircuitBreaker = apns.NewCircuitBreaker()
+	client.CircuitBreaker.MinRequests = 1
+	client.CircuitBreaker.OpenTimeout = 50 * time.Millisecond
+	client.RateLimiter = &apns.RateLimiter{DeviceTokenInterval: 300 * time.Millisecond, FailFast: true}
+	if res, err := client.Push(mockNotification()); err != nil || 503 != res.StatusCode {
+		t.Fatal("Expected:", 503, " found:", err)
+	}
+
+	// An open circuit rejects pushes before they reach the RateLimiter.
+	client.RateLimiter.FailFast = false
+	start := time.Now()
+	if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrCircuitOpen) {
+		t.Fatal("Expected:", apns.ErrCircuitOpen, " found:", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatal("Expected the push to fail fast, took:", elapsed)
+	}
+
+	// A trial refused by the RateLimiter frees its slot for the next push.
+	client.RateLimiter.FailFast = true
+	time.Sleep(client.CircuitBreaker.OpenTimeout)
+	if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrRateLimited) {
+		t.Fatal("Expected:", apns.ErrRateLimited, " found:", err)
+	}
+	n := mockNotification()
+	n.DeviceToken = strings.Repeat("cd", 32)
+	if res, err := client.Push(n); err != nil || 503 != res.StatusCode {
+		t.Fatal("Expected:", 503, " found:", err)
+	}
+}
@@ -0,0 +1,151 @@
+package apns2
+
+import "net/http"
+
+// Category is an actionable classification of a Response, telling whether the
+// notification should be retried, the device token removed or the
+// configuration fixed.
+type Category int
+
+const (
+	// CategorySuccess means the notification was accepted by APNs.
+	CategorySuccess Category = iota
+
+	// CategoryRetryable means APNs failed with a transient error and the
+	// notification can be sent again right away.
+	CategoryRetryable
+
+	// CategoryRetryAfterBackoff means APNs is throttling or unavailable and
+	// the notification should be sent again only after a backoff.
+	CategoryRetryAfterBackoff
+
+	// CategoryTokenInvalid means the device token is no longer valid for the
+	// topic and should be removed. The notification must not be retried.
+	CategoryTokenInvalid
+
+	// CategoryRequestError means the request was malformed. The notification
+	// must be fixed before being sent again.
+	CategoryRequestError
+
+	// CategoryConfigError means the credentials or the topic configuration
+	// of the provider are wrong. No notification will be accepted until the
+	// configuration is fixed.
+	CategoryConfigError
+)
+
+// reasonCategories maps every documented Reason to its Category:
+//
+//	Reason                       Status  Category
+//	BadCollapseId                400     CategoryRequestError
+//	BadDeviceToken               400     CategoryTokenInvalid
+//	BadExpirationDate            400     CategoryRequestError
+//	BadMessageId                 400     CategoryRequestError
+//	BadPriority                  400     CategoryRequestError
+//	BadTopic                     400     CategoryConfigError
+//	DeviceTokenNotForTopic       400     CategoryTokenInvalid
+//	DuplicateHeaders             400     CategoryRequestError
+//	IdleTimeout                  400     CategoryRetryable
+//	MissingDeviceToken           400     CategoryRequestError
+//	MissingTopic                 400     CategoryConfigError
+//	PayloadEmpty                 400     CategoryRequestError
+//	TopicDisallowed              400     CategoryConfigError
+//	BadCertificate               403     CategoryConfigError
+//	BadCertificateEnvironment    403     CategoryConfigError
+//	ExpiredProviderToken         403     CategoryConfigError
+//	Forbidden                    403     CategoryConfigError
+//	InvalidProviderToken         403     CategoryConfigError
+//	MissingProviderToken         403     CategoryConfigError
+//	BadPath                      404     CategoryRequestError
+//	MethodNotAllowed             405     CategoryRequestError
+//	Unregistered                 410     CategoryTokenInvalid
+//	PayloadTooLarge              413     CategoryRequestError
+//	TooManyProviderTokenUpdates  429     CategoryRetryAfterBackoff
+//	TooManyRequests              429     CategoryRetryAfterBackoff
+//	InternalServerError          500     CategoryRetryable
+//	ServiceUnavailable           503     CategoryRetryAfterBackoff
+//	Shutdown                     503     CategoryRetryable
+var reasonCategories = map[string]Category{
+	ReasonBadCollapseID:               CategoryRequestError,
+	ReasonBadDeviceToken:              CategoryTokenInvalid,
+	ReasonBadExpirationDate:           CategoryRequestError,
+	ReasonBadMessageID:                CategoryRequestError,
+	ReasonBadPriority:                 CategoryRequestError,
+	ReasonBadTopic:                    CategoryConfigError,
+	ReasonDeviceTokenNotForTopic:      CategoryTokenInvalid,
+	ReasonDuplicateHeaders:            CategoryRequestError,
+	ReasonIdleTimeout:                 CategoryRetryable,
+	ReasonMissingDeviceToken:          CategoryRequestError,
+	ReasonMissingTopic:                CategoryConfigError,
+	ReasonPayloadEmpty:                CategoryRequestError,
+	ReasonTopicDisallowed:             CategoryConfigError,
+	ReasonBadCertificate:              CategoryConfigError,
+	ReasonBadCertificateEnvironment:   CategoryConfigError,
+	ReasonExpiredProviderToken:        CategoryConfigError,
+	ReasonForbidden:                   CategoryConfigError,
+	ReasonInvalidProviderToken:        CategoryConfigError,
+	ReasonMissingProviderToken:        CategoryConfigError,
+	ReasonBadPath:                     CategoryRequestError,
+	ReasonMethodNotAllowed:            CategoryRequestError,
+	ReasonUnregistered:                CategoryTokenInvalid,
+	ReasonPayloadTooLarge:             CategoryRequestError,
+	ReasonTooManyProviderTokenUpdates: CategoryRetryAfterBackoff,
+	ReasonTooManyRequests:             CategoryRetryAfterBackoff,
+	ReasonInternalServerError:         CategoryRetryable,
+	ReasonServiceUnavailable:          CategoryRetryAfterBackoff,
+	ReasonShutdown:                    CategoryRetryable,
+}
+
+// Classify returns the Category of a response with the given status code and
+// reason. Reasons not documented by Apple are classified by status code: 410
+// as CategoryTokenInvalid, 403 as CategoryConfigError, 429 and 503 as
+// CategoryRetryAfterBackoff, other 5xx as CategoryRetryable and anything else
+// as CategoryRequestError. APNs only answers 410 for inactive device tokens,
+// so an unknown reason with that status still gets the token removed.
+func Classify(statusCode int, reason string) Category {
+	if statusCode == StatusSent {
+		return CategorySuccess
+	}
+	if category, ok := reasonCategories[reason]; ok {
+		return category
+	}
+	switch {
+	case statusCode == http.StatusGone:
+		return CategoryTokenInvalid
+	case statusCode == http.StatusForbidden:
+		return CategoryConfigError
+	case statusCode == http.StatusTooManyRequests, statusCode == http.StatusServiceUnavailable:
+		return CategoryRetryAfterBackoff
+	case statusCode >= 500 && statusCode < 600:
+		return CategoryRetryable
+	}
+	return CategoryRequestError
+}
+
+// Category returns the Category of the response. See Classify.
+func (c *Response) Category() Category {
+	return Classify(c.StatusCode, c.Reason)
+}
+
+// Retryable reports whether a notification in this category may be sent
+// again, either right away or after a backoff.
+func (c Category) Retryable() bool {
+	return c == CategoryRetryable || c == CategoryRetryAfterBackoff
+}
+
+func (c Category) String() string {
+	switch c {
+	case CategorySuccess:
+		return "Success"
+	case CategoryRetryable:
+		return "Retryable"
+	case CategoryRetryAfterBackoff:
+		return "RetryAfterBackoff"
+	case CategoryTokenInvalid:
+		return "TokenInvalid"
+	case CategoryRequestError:
+		return "RequestError"
+	case CategoryConfigError:
+		return "ConfigError"
+	}
+	return "Unknown"
+}
@@ -0,0 +1,92 @@
+package apns2_test
+
+import (
+	"testing"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+func TestClassifyReasons(t *testing.T) {
+	scenarios := []struct {
+		status   int
+		reason   string
+		category apns.Category
+	}{
+		{200, "", apns.CategorySuccess},
+		{400, apns.ReasonBadCollapseID, apns.CategoryRequestError},
+		{400, apns.ReasonBadDeviceToken, apns.CategoryTokenInvalid},
+		{400, apns.ReasonBadExpirationDate, apns.CategoryRequestError},
+		{400, apns.ReasonBadMessageID, apns.CategoryRequestError},
+		{400, apns.ReasonBadPriority, apns.CategoryRequestError},
+		{400, apns.ReasonBadTopic, apns.CategoryConfigError},
+		{400, apns.ReasonDeviceTokenNotForTopic, apns.CategoryTokenInvalid},
+		{400, apns.ReasonDuplicateHeaders, apns.CategoryRequestError},
+		{400, apns.ReasonIdleTimeout, apns.CategoryRetryable},
+		{400, apns.ReasonMissingDeviceToken, apns.CategoryRequestError},
+		{400, apns.ReasonMissingTopic, apns.CategoryConfigError},
+		{400, apns.ReasonPayloadEmpty, apns.CategoryRequestError},
+		{400, apns.ReasonTopicDisallowed, apns.CategoryConfigError},
+		{403, apns.ReasonBadCertificate, apns.CategoryConfigError},
+		{403, apns.ReasonBadCertificateEnvironment, apns.CategoryConfigError},
+		{403, apns.ReasonExpiredProviderToken, apns.CategoryConfigError},
+		{403, apns.ReasonForbidden, apns.CategoryConfigError},
+		{403, apns.ReasonInvalidProviderToken, apns.CategoryConfigError},
+		{403, apns.ReasonMissingProviderToken, apns.CategoryConfigError},
+		{404, apns.ReasonBadPath, apns.CategoryRequestError},
+		{405, apns.ReasonMethodNotAllowed, apns.CategoryRequestError},
+		{410, apns.ReasonUnregistered, apns.CategoryTokenInvalid},
+		{413, apns.ReasonPayloadTooLarge, apns.CategoryRequestError},
+		{429, apns.ReasonTooManyProviderTokenUpdates, apns.CategoryRetryAfterBackoff},
+		{429, apns.ReasonTooManyRequests, apns.CategoryRetryAfterBackoff},
+		{500, apns.ReasonInternalServerError, apns.CategoryRetryable},
+		{503, apns.ReasonServiceUnavailable, apns.CategoryRetryAfterBackoff},
+		{503, apns.ReasonShutdown, apns.CategoryRetryable},
+	}
+
+	for _, scenario := range scenarios {
+		res := &apns.Response{StatusCode: scenario.status, Reason: scenario.reason}
+		if scenario.category != res.Category() {
+			t.Fatal("Expected:", scenario.category, " found:", res.Category(), "for", scenario.reason)
+		}
+	}
+}
+
+func TestClassifyUnknownReasons(t *testing.T) {
+	scenarios := []struct {
+		status   int
+		category apns.Category
+	}{
+		{400, apns.CategoryRequestError},
+		{403, apns.CategoryConfigError},
+		{410, apns.CategoryTokenInvalid},
+		{429, apns.CategoryRetryAfterBackoff},
+		{500, apns.CategoryRetryable},
+		{502, apns.CategoryRetryable},
+		{503, apns.CategoryRetryAfterBackoff},
+		{0, apns.CategoryRequestError},
+	}
+
+	for _, scenario := range scenarios {
+		if scenario.category != apns.Classify(scenario.status, "SomethingNew") {
+			t.Fatal("Expected:", scenario.category, " found:", apns.Classify(scenario.status, "SomethingNew"), "for", scenario.status)
+		}
+	}
+}
+
+func TestCategoryRetryable(t *testing.T) {
+	if !apns.CategoryRetryable.Retryable() || !apns.CategoryRetryAfterBackoff.Retryable() {
+		t.Fatal("Expected retryable categories")
+	}
+	if apns.CategoryTokenInvalid.Retryable() || apns.CategorySuccess.Retryable() {
+		t.Fatal("Expected non retryable categories")
+	}
+}
+
+func TestCategoryString(t *testing.T) {
+	if "TokenInvalid" != apns.CategoryTokenInvalid.String() {
+		t.Fatal("Expected:", "TokenInvalid", " found:", apns.CategoryTokenInvalid.String())
+	}
+	if "Unknown" != apns.Category(42).String() {
+		t.Fatal("Expected:", "Unknown", " found:", apns.Category(42).String())
+	}
+}
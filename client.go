@@ -13,6 +13,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/sapienzaapps/apns2/token"
@@ -39,16 +40,25 @@ var (
 	// TCPKeepAlive specifies the keep-alive period for an active network
 	// connection. If zero, keep-alives are not enabled.
 	TCPKeepAlive = 60 * time.Second
+	// ReadIdleTimeout is the interval after which, if no frame was received
+	// on a connection, a PING frame is sent to check its health. It keeps idle
+	// connections alive and detects the dead ones before they are used. If
+	// zero, no health check is performed.
+	ReadIdleTimeout = 30 * time.Second
+	// PingTimeout is how long to wait for the answer to a health check PING
+	// frame before closing the connection.
+	PingTimeout = 15 * time.Second
 )
 
 // DialTLS is the default dial function for creating TLS connections for
-// non-proxied HTTPS requests.
+// non-proxied HTTPS requests, used by NewClient and NewTokenClient. Failures
+// are reported as a TransportError of kind ErrDial or ErrTLSHandshake.
 var DialTLS = func(network, addr string, cfg *tls.Config) (net.Conn, error) {
 	dialer := &net.Dialer{
 		Timeout:   TLSDialTimeout,
 		KeepAlive: TCPKeepAlive,
 	}
-	return tls.DialWithDialer(dialer, network, addr, cfg)
+	return dialTLS(dialer.Dial, TLSDialTimeout, network, addr, cfg)
 }
 
 // Client represents a connection with the APNs
@@ -57,6 +67,59 @@ type Client struct {
 	Certificate tls.Certificate
 	Token       *token.Token
 	HTTPClient  *http.Client
+
+	// RetryPolicy controls how notifications failing with a transient error
+	// are sent again. If nil, each notification is sent only once.
+	RetryPolicy *RetryPolicy
+
+	// ValidateNotifications makes the Client check every notification with
+	// Notification.Validate before sending it, returning the ValidationErrors
+	// instead of contacting APNs.
+	ValidateNotifications bool
+
+	// TokenFeedback, if set, is notified of every device token which APNs
+	// reports as no longer valid, so that it can be removed.
+	TokenFeedback TokenFeedback
+
+	// DryRun, if set, makes the Client validate and build every request
+	// without sending it, answering with a synthetic response instead.
+	DryRun *DryRun
+
+	// Interceptors are run, in order, around the HTTP round trip of every
+	// request sent to APNs.
+	Interceptors []Interceptor
+
+	// Metrics, if set, receives measurements of the requests sent to APNs.
+	Metrics Metrics
+
+	// Tracer, if set, starts a span for every notification pushed, with
+	// events for the connection, the TLS handshake and the response.
+	Tracer Tracer
+
+	// Logger, if set, receives the events of the Client, redacted according
+	// to LogRedaction.
+	Logger       Logger
+	LogRedaction LogRedaction
+
+	// RateLimiter, if set, caps the pace of the requests sent to APNs.
+	RateLimiter *RateLimiter
+
+	// CircuitBreaker, if set, fails the pushes fast during APNs outages.
+	CircuitBreaker *CircuitBreaker
+
+	// MaxReplays is the maximum number of times a notification is sent again
+	// over a new connection because APNs did not process it, as guaranteed
+	// by a GOAWAY frame whose last stream ID is lower than the stream of the
+	// notification, or by a REFUSED_STREAM reset. Replays are independent of
+	// the RetryPolicy. Zero disables them.
+	MaxReplays int
+
+	// FallbackHosts are the endpoints tried, in order, when no connection
+	// can be established with the Host. The first one working is used for
+	// the following pushes, until it fails in turn.
+	FallbackHosts []string
+
+	endpoint int32
 }
 
 // A Context carries a deadline, a cancellation signal, and other values across
@@ -82,25 +145,10 @@ type connectionCloser interface {
 // If your use case involves multiple long-lived connections, consider using
 // the ClientManager, which manages clients for you.
 func NewClient(certificate tls.Certificate) *Client {
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{certificate},
-		MinVersion:   tls.VersionTLS12,
-	}
-	if len(certificate.Certificate) > 0 {
-		tlsConfig.BuildNameToCertificate()
-	}
-	transport := &http2.Transport{
-		TLSClientConfig: tlsConfig,
-		DialTLS:         DialTLS,
-	}
-	return &Client{
-		HTTPClient: &http.Client{
-			Transport: transport,
-			Timeout:   HTTPClientTimeout,
-		},
-		Certificate: certificate,
-		Host:        DefaultHost,
-	}
+	config := DefaultClientConfig()
+	config.Certificate = &certificate
+	config.DialTLS = DialTLS
+	return newClient(config)
 }
 
 // NewTokenClient returns a new Client with an underlying http.Client configured
@@ -112,17 +160,10 @@ func NewClient(certificate tls.Certificate) *Client {
 // notifications; don’t repeatedly open and close connections. APNs treats rapid
 // connection and disconnection as a denial-of-service attack.
 func NewTokenClient(token *token.Token) *Client {
-	transport := &http2.Transport{
-		DialTLS: DialTLS,
-	}
-	return &Client{
-		Token: token,
-		HTTPClient: &http.Client{
-			Transport: transport,
-			Timeout:   HTTPClientTimeout,
-		},
-		Host: DefaultHost,
-	}
+	config := DefaultClientConfig()
+	config.Token = token
+	config.DialTLS = DialTLS
+	return newClient(config)
 }
 
 // Development sets the Client to use the APNs development push endpoint.
@@ -137,6 +178,30 @@ func (c *Client) Production() *Client {
 	return c
 }
 
+// WithConnPool makes the Client spread its requests over up to size HTTP/2
+// connections per host, selected according to strategy, instead of a single
+// one. It has no effect if the transport of the underlying http.Client is not
+// an *http2.Transport, as set by NewClient and NewTokenClient, or a ConnPool.
+func (c *Client) WithConnPool(size int, strategy PoolStrategy) *Client {
+	switch transport := c.HTTPClient.Transport.(type) {
+	case *http2.Transport:
+		c.HTTPClient.Transport = NewConnPool(transport, size, strategy)
+	case *ConnPool:
+		transport.Size = size
+		transport.Strategy = strategy
+	}
+	return c
+}
+
+// ConnStats returns the state of the pooled connections of the Client, or nil
+// if the Client does not use a ConnPool.
+func (c *Client) ConnStats() []ConnStats {
+	if pool, ok := c.HTTPClient.Transport.(*ConnPool); ok {
+		return pool.Stats()
+	}
+	return nil
+}
+
 // Push sends a Notification to the APNs gateway. If the underlying http.Client
 // is not currently connected, this method will attempt to reconnect
 // transparently before sending the notification. It will return a Response
@@ -156,34 +221,219 @@ func (c *Client) Push(n *Notification) (*Response, error) {
 // If the underlying http.Client is not currently connected, this method will
 // attempt to reconnect transparently before sending the notification. It will
 // return a Response indicating whether the notification was accepted or
-// rejected by the APNs gateway, or an error if something goes wrong.
+// rejected by the APNs gateway, or an error if something goes wrong. Connection
+// failures are reported as a TransportError when their kind is recognized.
 func (c *Client) PushWithContext(ctx Context, n *Notification) (*Response, error) {
 	payload, err := json.Marshal(n)
 	if err != nil {
 		return nil, err
 	}
 
-	url := fmt.Sprintf("%v/3/device/%v", c.Host, n.DeviceToken)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+	return c.push(ctx, n, payload)
+}
+
+// push sends the already marshalled payload of n, retrying according to the
+// Client RetryPolicy, and reports dead device tokens to the TokenFeedback.
+func (c *Client) push(ctx Context, n *Notification, payload []byte) (*Response, error) {
+	ctx, endSpan := c.startSpan(ctx, n)
+	if c.ValidateNotifications || c.DryRun != nil {
+		if errs := n.validate(payload); len(errs) > 0 {
+			endSpan(nil, errs)
+			return nil, errs
+		}
+	}
+
+	response, err := c.retry(ctx, n, payload)
+	endSpan(response, err)
+	if err == nil && c.TokenFeedback != nil && response.Category() == CategoryTokenInvalid {
+		c.TokenFeedback.Report(DeadToken{
+			DeviceToken: n.DeviceToken,
+			Topic:       n.Topic,
+			Reason:      response.Reason,
+			Timestamp:   response.Timestamp,
+		})
+	}
+	return response, err
+}
+
+// retry sends the notification until it is delivered or the Client
+// RetryPolicy gives up.
+func (c *Client) retry(ctx Context, n *Notification, payload []byte) (*Response, error) {
+	for attempt := 1; ; attempt++ {
+		var generation uint64
+		if c.CircuitBreaker != nil {
+			var err error
+			if generation, err = c.CircuitBreaker.allow(); err != nil {
+				return nil, err
+			}
+		}
+		if c.RateLimiter != nil {
+			if err := c.RateLimiter.wait(ctx, n); err != nil {
+				if c.CircuitBreaker != nil {
+					c.CircuitBreaker.release(generation)
+				}
+				return nil, err
+			}
+		}
+		response, err := c.send(ctx, n, payload)
+		if c.CircuitBreaker != nil {
+			c.CircuitBreaker.record(generation, response, err)
+		}
+		if response != nil {
+			response.Attempts = attempt
+		}
+		policy := c.RetryPolicy
+		if policy == nil || attempt >= policy.MaxAttempts || !policy.Retryable(response, err) {
+			return response, err
+		}
+		if !policy.wait(ctx, attempt) {
+			return response, err
+		}
+	}
+}
+
+// send performs a round trip to APNs. In token mode, if APNs rejects the
+// provider token, the token is regenerated and the notification is sent once
+// more with the new bearer.
+func (c *Client) send(ctx Context, n *Notification, payload []byte) (*Response, error) {
+	var bearer string
+	if c.Token != nil {
+		var renewed bool
+		var err error
+		if bearer, renewed, err = c.Token.Renew(); err != nil {
+			return nil, err
+		}
+		if renewed {
+			c.tokenGenerated()
+		}
+	}
+
+	response, err := c.fallback(ctx, n, payload, bearer)
+	if err != nil || bearer == "" || !providerTokenRejected(response) {
+		return response, err
+	}
+
+	fresh, refreshErr := c.Token.Refresh(bearer)
+	if refreshErr != nil || fresh == bearer {
+		return response, nil
+	}
+	c.tokenGenerated()
+	if c.RateLimiter != nil {
+		if err := c.RateLimiter.wait(ctx, n); err != nil {
+			return nil, err
+		}
+	}
+	return c.fallback(ctx, n, payload, fresh)
+}
+
+// roundTrip sends the notification to APNs exactly once, through the Client
+// Interceptors.
+func (c *Client) roundTrip(ctx Context, n *Notification, payload []byte, bearer, host string) (*Response, error) {
+	req, err := newRequest(n, payload, bearer, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	exchange := &Exchange{Request: req}
+	ctx = context.WithValue(ctx, exchangeKey{}, exchange)
+	push := c.chain(func(ctx Context, sent *Notification) (*Response, error) {
+		if sent != n {
+			// An interceptor replaced the notification, so the request is
+			// built again from the one it passed to next.
+			payload, err := sent.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			if exchange.Request, err = newRequest(sent, payload, bearer, host); err != nil {
+				return nil, err
+			}
+		}
+		return c.exchange(ctx, sent, exchange)
+	})
+	return push(ctx, n)
+}
+
+// newRequest builds the HTTP request sending the notification to host.
+func newRequest(n *Notification, payload []byte, bearer, host string) (*http.Request, error) {
+	url := fmt.Sprintf("%v/3/device/%v", host, n.DeviceToken)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
 	if err != nil {
 		return nil, err
 	}
 
-	if c.Token != nil {
-		c.setTokenHeader(req)
+	if bearer != "" {
+		setTokenHeader(req, bearer)
 	}
 
 	setHeaders(req, n)
+	return req, nil
+}
 
-	httpRes, err := c.requestWithContext(ctx, req)
+// exchange sends the request of the Exchange and decodes the response,
+// reporting the request to the Client Metrics and Logger.
+func (c *Client) exchange(ctx Context, n *Notification, exchange *Exchange) (*Response, error) {
+	if c.Metrics == nil && c.Logger == nil {
+		return c.do(ctx, exchange)
+	}
+	if c.Logger != nil {
+		c.log(c.LogRedaction.requestEvent(LogRequestSent, n, exchange.Request))
+	}
+	if c.Metrics != nil {
+		c.Metrics.RequestStarted()
+	}
+	start := time.Now()
+	response, err := c.do(ctx, exchange)
+	duration := time.Since(start)
+	if c.Logger != nil {
+		event := c.LogRedaction.requestEvent(LogResponseReceived, n, exchange.Request)
+		event.Err = err
+		event.Duration = duration
+		if response != nil {
+			event.ApnsID = response.ApnsID
+			event.StatusCode = response.StatusCode
+			event.Reason = response.Reason
+		}
+		c.log(event)
+	}
+	if c.Metrics == nil {
+		return response, err
+	}
+	metrics := RequestMetrics{
+		Topic:     exchange.Request.Header.Get("apns-topic"),
+		PushType:  EPushType(exchange.Request.Header.Get("apns-push-type")),
+		Err:       err,
+		Duration:  duration,
+		BytesSent: exchange.Request.ContentLength,
+	}
+	if response != nil {
+		metrics.StatusCode = response.StatusCode
+		metrics.Reason = response.Reason
+	}
+	c.Metrics.RequestFinished(metrics)
+	return response, err
+}
+
+func (c *Client) do(ctx Context, exchange *Exchange) (*Response, error) {
+	if ctx != nil {
+		ctx = withClientTrace(ctx)
+	}
+	var replays int32
+	ctx = withReplayCount(ctx, &replays)
+	httpRes, err := c.requestWithContext(ctx, exchange.Request)
 	if err != nil {
-		return nil, err
+		return nil, transportError(err)
 	}
 	defer httpRes.Body.Close()
+	exchange.Response = httpRes
 
 	response := &Response{}
 	response.StatusCode = httpRes.StatusCode
 	response.ApnsID = httpRes.Header.Get("apns-id")
+	response.Replays = int(atomic.LoadInt32(&replays))
+	response.Host = exchange.Request.URL.Scheme + "://" + exchange.Request.URL.Host
 
 	decoder := json.NewDecoder(httpRes.Body)
 	if err := decoder.Decode(&response); err != nil && !errors.Is(err, io.EOF) {
@@ -192,6 +442,13 @@ func (c *Client) PushWithContext(ctx Context, n *Notification) (*Response, error
 	return response, nil
 }
 
+func (c *Client) tokenGenerated() {
+	if c.Metrics != nil {
+		c.Metrics.TokenGenerated()
+	}
+	c.log(LogEvent{Type: LogTokenGenerated, Time: time.Now()})
+}
+
 // CloseIdleConnections closes any underlying connections which were previously
 // connected from previous requests but are now sitting idle. It will not
 // interrupt any connections currently in use.
@@ -199,11 +456,14 @@ func (c *Client) CloseIdleConnections() {
 	c.HTTPClient.Transport.(connectionCloser).CloseIdleConnections()
 }
 
-func (c *Client) setTokenHeader(r *http.Request) {
-	bearer := c.Token.GenerateIfExpired()
+func setTokenHeader(r *http.Request, bearer string) {
 	r.Header.Set("authorization", fmt.Sprintf("bearer %v", bearer))
 }
 
+func providerTokenRejected(r *Response) bool {
+	return r.Reason == ReasonExpiredProviderToken || r.Reason == ReasonInvalidProviderToken
+}
+
 func setHeaders(r *http.Request, n *Notification) {
 	r.Header.Set("Content-Type", "application/json; charset=utf-8")
 	if n.Topic != "" {
@@ -232,5 +492,8 @@ func (c *Client) requestWithContext(ctx Context, req *http.Request) (*http.Respo
 	if ctx != nil {
 		req = req.WithContext(ctx)
 	}
+	if c.DryRun != nil {
+		return c.DryRun.roundTrip(req)
+	}
 	return c.HTTPClient.Do(req)
 }
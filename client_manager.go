@@ -31,6 +31,9 @@ type ClientManager struct {
 	// manager.
 	Factory func(certificate tls.Certificate) *Client
 
+	// Metrics, if set, is notified of the evicted clients.
+	Metrics Metrics
+
 	cache map[[sha256.Size]byte]*list.Element
 	ll    *list.List
 	mu    sync.Mutex
@@ -102,6 +105,7 @@ func (m *ClientManager) Get(certificate tls.Certificate) *Client {
 				return nil
 			}
 			item.client = c
+			m.clientEvicted()
 		}
 		item.lastUsed = now
 		m.ll.MoveToFront(ele)
@@ -149,6 +153,13 @@ func (m *ClientManager) removeElement(e *list.Element) {
 	defer m.mu.Unlock()
 	m.ll.Remove(e)
 	delete(m.cache, e.Value.(*managerItem).key)
+	m.clientEvicted()
+}
+
+func (m *ClientManager) clientEvicted() {
+	if m.Metrics != nil {
+		m.Metrics.ClientEvicted()
+	}
 }
 
 func cacheKey(certificate tls.Certificate) [sha256.Size]byte {
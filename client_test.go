@@ -13,6 +13,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -43,7 +45,7 @@ func mockCert() tls.Certificate {
 }
 
 func mockClient(url string) *apns.Client {
-	return &apns.Client{Host: url, HTTPClient: http.DefaultClient}
+	return &apns.Client{Host: url, HTTPClient: &http.Client{}}
 }
 
 type mockTransport struct {
@@ -153,6 +155,7 @@ func TestClientNameToCertificate(t *testing.T) {
 }
 
 func TestDialTLSTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { apns.TLSDialTimeout = timeout }(apns.TLSDialTimeout)
 	apns.TLSDialTimeout = 10 * time.Millisecond
 	crt, _ := certificate.FromP12File("certificate/_fixtures/certificate-valid.p12", "")
 	client := apns.NewClient(crt)
@@ -550,3 +553,70 @@ func TestCloseIdleConnections(t *testing.T) {
 		t.Fatal("Expected:", transport.closed, " found:", true)
 	}
 }
+
+func TestExpiredProviderTokenRefresh(t *testing.T) {
+	token := mockToken()
+	_, _ = token.Generate()
+	token.IssuedAt = time.Now().Add(-30 * time.Minute).Unix()
+	stale := fmt.Sprintf("bearer %v", token.Bearer)
+	bearers := map[string]int{}
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		bearers[r.Header.Get("authorization")]++
+		mu.Unlock()
+		if stale == r.Header.Get("authorization") {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"reason":"ExpiredProviderToken"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.Token = token
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := client.Push(mockNotification())
+			if err != nil {
+				t.Error("Expected no error, found:", err)
+				return
+			}
+			if !res.Sent() {
+				t.Error("Expected:", true, " found:", res.Sent())
+			}
+		}()
+	}
+	wg.Wait()
+	if 2 != len(bearers) {
+		t.Fatal("Expected:", 2, " found:", len(bearers))
+	}
+	if 10 != bearers[fmt.Sprintf("bearer %v", token.Bearer)] {
+		t.Fatal("Expected:", 10, " found:", bearers[fmt.Sprintf("bearer %v", token.Bearer)])
+	}
+}
+
+func TestExpiredProviderTokenRefreshTooSoon(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"reason":"ExpiredProviderToken"}`))
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.Token = mockToken()
+	res, err := client.Push(mockNotification())
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if apns.ReasonExpiredProviderToken != res.Reason {
+		t.Fatal("Expected:", apns.ReasonExpiredProviderToken, " found:", res.Reason)
+	}
+	if 1 != atomic.LoadInt32(&requests) {
+		t.Fatal("Expected:", 1, " found:", requests)
+	}
+}
@@ -0,0 +1,152 @@
+package apns2_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/apns2test"
+	"golang.org/x/net/http2"
+)
+
+func TestNewClientWithOptionsDefaults(t *testing.T) {
+	authToken := mockToken()
+	client, err := apns.NewClientWithOptions(apns.WithToken(authToken))
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if apns.DefaultHost != client.Host || authToken != client.Token {
+		t.Fatal("Unexpected client:", client.Host, client.Token)
+	}
+	if apns.HTTPClientTimeout != client.HTTPClient.Timeout {
+		t.Fatal("Expected:", apns.HTTPClientTimeout, " found:", client.HTTPClient.Timeout)
+	}
+	transport := client.HTTPClient.Transport.(*http2.Transport)
+	if apns.ReadIdleTimeout != transport.ReadIdleTimeout || apns.PingTimeout != transport.PingTimeout {
+		t.Fatal("Expected default health checks, found:", transport.ReadIdleTimeout, transport.PingTimeout)
+	}
+	if apns.MaxReplays != client.MaxReplays {
+		t.Fatal("Expected:", apns.MaxReplays, " found:", client.MaxReplays)
+	}
+	if client := apns.NewClient(tls.Certificate{}); apns.MaxReplays != client.MaxReplays {
+		t.Fatal("Expected:", apns.MaxReplays, " found:", client.MaxReplays)
+	}
+	if client, _ := apns.NewClientWithOptions(apns.WithToken(authToken), apns.WithMaxReplays(0)); 0 != client.MaxReplays {
+		t.Fatal("Expected:", 0, " found:", client.MaxReplays)
+	}
+}
+
+func TestNewClientWithOptions(t *testing.T) {
+	var configured *http2.Transport
+	client, err := apns.NewClientWithOptions(
+		apns.WithCertificate(tls.Certificate{}),
+		apns.WithHost(apns.HostProduction),
+		apns.WithHTTPClientTimeout(5*time.Second),
+		apns.WithHealthCheck(0, 0),
+		apns.WithTransport(func(transport *http2.Transport) {
+			configured = transport
+		}),
+	)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if apns.HostProduction != client.Host || 5*time.Second != client.HTTPClient.Timeout {
+		t.Fatal("Unexpected client:", client.Host, client.HTTPClient.Timeout)
+	}
+	transport := client.HTTPClient.Transport.(*http2.Transport)
+	if configured != transport || 0 != transport.ReadIdleTimeout {
+		t.Fatal("Expected configured transport without health checks, found:", transport.ReadIdleTimeout)
+	}
+	if 1 != len(transport.TLSClientConfig.Certificates) {
+		t.Fatal("Expected:", 1, " found:", len(transport.TLSClientConfig.Certificates))
+	}
+
+	for _, options := range [][]apns.ClientOption{
+		{apns.WithToken(mockToken())},
+		{apns.WithToken(mockToken()), apns.WithProxy(&url.URL{Scheme: "http", Host: "proxy:3128"})},
+	} {
+		client, _ = apns.NewClientWithOptions(options...)
+		tlsConfig := client.HTTPClient.Transport.(*http2.Transport).TLSClientConfig
+		if tlsConfig == nil || tls.VersionTLS12 != tlsConfig.MinVersion {
+			t.Fatal("Expected TLS 1.2 minimum for token clients, found:", tlsConfig)
+		}
+	}
+
+	client, _ = apns.NewClientWithOptions(apns.WithToken(mockToken()), apns.WithConnPool(2, apns.PoolRoundRobin))
+	if pool, ok := client.HTTPClient.Transport.(*apns.ConnPool); !ok || 2 != pool.Size {
+		t.Fatal("Expected connection pool, found:", client.HTTPClient.Transport)
+	}
+}
+
+func TestNewClientWithOptionsErrors(t *testing.T) {
+	cases := [][]apns.ClientOption{
+		{},
+		{apns.WithToken(mockToken()), apns.WithCertificate(tls.Certificate{})},
+		{apns.WithToken(mockToken()), apns.WithHost("api.push.apple.com")},
+		{apns.WithToken(mockToken()), apns.WithHost("ftp://api.push.apple.com")},
+		{apns.WithToken(mockToken()), apns.WithHost("http://api.push.apple.com")},
+		{apns.WithToken(mockToken()), apns.WithFallbackHosts("http://api.push.apple.com")},
+		{apns.WithToken(mockToken()), apns.WithTLSDialTimeout(-time.Second)},
+		{apns.WithToken(mockToken()), apns.WithMaxReplays(-1)},
+	}
+	for i, options := range cases {
+		if client, err := apns.NewClientWithOptions(options...); err == nil || client != nil {
+			t.Fatal("Expected error for case", i, " found:", client)
+		}
+	}
+}
+
+func TestNewClientWithOptionsServer(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	certificate := apns2test.NewCertificate()
+	server.TrustCertificate(certificate)
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate)
+	tlsConfig := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+
+	client, err := apns.NewClientWithOptions(
+		apns.WithCertificate(certificate),
+		apns.WithHost(server.URL),
+		apns.WithTLSConfig(tlsConfig),
+		apns.WithDialer(&net.Dialer{Timeout: time.Second}),
+	)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	if 0 != len(tlsConfig.Certificates) {
+		t.Fatal("Expected the TLS config not to be changed, found:", len(tlsConfig.Certificates))
+	}
+}
+
+func TestNewClientWithOptionsTLSDialTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	client, err := apns.NewClientWithOptions(
+		apns.WithToken(mockToken()),
+		apns.WithHost("https://"+listener.Addr().String()),
+		apns.WithTLSDialTimeout(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	start := time.Now()
+	if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrTLSHandshake) {
+		t.Fatal("Expected:", apns.ErrTLSHandshake, " found:", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatal("Expected the per-client timeout, found:", elapsed)
+	}
+}
@@ -0,0 +1,94 @@
+package apns2
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trackedConn is a TLS connection established by DialTLS, remembering how
+// long the dial and the handshake took.
+type trackedConn struct {
+	*tls.Conn
+	dialStart     time.Time
+	connected     time.Time
+	handshakeDone time.Time
+
+	// onClose, if set, is called the first time the connection is closed.
+	onClose   func(err error)
+	closeOnce sync.Once
+}
+
+// Close closes the connection.
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		if c.onClose != nil {
+			c.onClose(err)
+		}
+	})
+	return err
+}
+
+// dialTLS dials a connection with dial and runs the TLS handshake over it.
+// Like tls.DialWithDialer, the timeout covers both the dial and the
+// handshake. Failures are reported as a TransportError of kind ErrDial or
+// ErrTLSHandshake.
+func dialTLS(dial func(network, addr string) (net.Conn, error), timeout time.Duration, network, addr string, cfg *tls.Config) (net.Conn, error) {
+	start := time.Now()
+	rawConn, err := dial(network, addr)
+	if err != nil {
+		return nil, &TransportError{Kind: ErrDial, Err: err}
+	}
+	conn, err := handshake(rawConn, addr, cfg, start, timeout)
+	if err != nil {
+		_ = rawConn.Close()
+		return nil, &TransportError{Kind: ErrTLSHandshake, Err: err}
+	}
+	return conn, nil
+}
+
+// handshake runs the TLS handshake over the connection dialed at start, which
+// must complete within timeout of it. The server name defaults to the host of
+// addr.
+func handshake(rawConn net.Conn, addr string, cfg *tls.Config, start time.Time, timeout time.Duration) (*trackedConn, error) {
+	connected := time.Now()
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName == "" {
+		host := addr
+		if i := strings.LastIndex(addr, ":"); i != -1 {
+			host = addr[:i]
+		}
+		cfg = cfg.Clone()
+		cfg.ServerName = host
+	}
+
+	if timeout > 0 {
+		if err := rawConn.SetDeadline(start.Add(timeout)); err != nil {
+			return nil, err
+		}
+	}
+	conn := tls.Client(rawConn, cfg)
+	if err := conn.Handshake(); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, fmt.Errorf("tls: handshake timed out: %w", err)
+		}
+		return nil, err
+	}
+	if err := rawConn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	return &trackedConn{
+		Conn:          conn,
+		dialStart:     start,
+		connected:     connected,
+		handshakeDone: time.Now(),
+	}, nil
+}
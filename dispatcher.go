@@ -0,0 +1,196 @@
+package apns2
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultDispatcherQueueSize is the capacity of the Dispatcher queue when no
+// queue size is configured.
+const DefaultDispatcherQueueSize = 1024
+
+// BackpressurePolicy defines how Dispatcher.Enqueue behaves when the
+// Dispatcher queue is full.
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock makes Enqueue wait until there is room in the queue.
+	BackpressureBlock BackpressurePolicy = iota
+
+	// BackpressureDrop makes Enqueue discard the notification and return
+	// successfully. Discarded notifications are counted by Dropped.
+	BackpressureDrop
+
+	// BackpressureError makes Enqueue return ErrQueueFull.
+	BackpressureError
+)
+
+// Possible errors when enqueueing a notification in a Dispatcher.
+var (
+	ErrDispatcherClosed = errors.New("apns2: dispatcher is closed")
+	ErrQueueFull        = errors.New("apns2: dispatcher queue is full")
+)
+
+// DispatcherOptions configures a Dispatcher.
+type DispatcherOptions struct {
+	// Workers is the number of notifications sent at the same time. If zero,
+	// DefaultPushConcurrency is used.
+	Workers int
+
+	// QueueSize is the number of notifications which can be enqueued while
+	// all the workers are busy. If zero, DefaultDispatcherQueueSize is used.
+	QueueSize int
+
+	// Backpressure defines how Enqueue behaves when the queue is full.
+	Backpressure BackpressurePolicy
+
+	// Callback, if set, is called by the workers with the result of every
+	// push. Otherwise, results are delivered on the Results channel.
+	Callback func(PushResult)
+}
+
+// Dispatcher sends notifications asynchronously through a Pusher, usually a
+// Client, using a fixed pool of workers fed by a bounded queue.
+type Dispatcher struct {
+	pusher   Pusher
+	opts     DispatcherOptions
+	queue    chan *Notification
+	results  chan PushResult
+	closing  chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup
+	mu       sync.RWMutex
+	closed   bool
+	once     sync.Once
+	dropped  uint64
+	finished chan struct{}
+}
+
+// NewDispatcher returns a new Dispatcher sending notifications with pusher
+// and starts its workers. opts can be nil.
+//
+// If no Callback is set, the results must be received from the Results
+// channel, otherwise the workers stop once its buffer is full.
+func NewDispatcher(pusher Pusher, opts *DispatcherOptions) *Dispatcher {
+	d := &Dispatcher{
+		pusher:   pusher,
+		closing:  make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	if opts != nil {
+		d.opts = *opts
+	}
+	if d.opts.Workers <= 0 {
+		d.opts.Workers = DefaultPushConcurrency
+	}
+	if d.opts.QueueSize <= 0 {
+		d.opts.QueueSize = DefaultDispatcherQueueSize
+	}
+	d.queue = make(chan *Notification, d.opts.QueueSize)
+	if d.opts.Callback == nil {
+		d.results = make(chan PushResult, d.opts.QueueSize)
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+
+	d.workers.Add(d.opts.Workers)
+	for w := 0; w < d.opts.Workers; w++ {
+		go d.work()
+	}
+	go func() {
+		d.workers.Wait()
+		d.cancel()
+		if d.results != nil {
+			close(d.results)
+		}
+		close(d.finished)
+	}()
+	return d
+}
+
+// Enqueue adds the notification to the Dispatcher queue without waiting for
+// it to be sent. When the queue is full, it blocks, drops the notification or
+// returns ErrQueueFull according to the configured BackpressurePolicy. It
+// returns ErrDispatcherClosed once Close has been called.
+func (d *Dispatcher) Enqueue(n *Notification) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrDispatcherClosed
+	}
+	if d.opts.Backpressure == BackpressureBlock {
+		select {
+		case d.queue <- n:
+			return nil
+		case <-d.closing:
+			return ErrDispatcherClosed
+		}
+	}
+	select {
+	case d.queue <- n:
+		return nil
+	default:
+	}
+	if d.opts.Backpressure == BackpressureDrop {
+		atomic.AddUint64(&d.dropped, 1)
+		return nil
+	}
+	return ErrQueueFull
+}
+
+// Results returns the channel on which push results are delivered, or nil if
+// a Callback was configured. The channel is closed once the Dispatcher is
+// closed and drained.
+func (d *Dispatcher) Results() <-chan PushResult {
+	return d.results
+}
+
+// Dropped returns the number of notifications discarded by Enqueue because
+// the queue was full.
+func (d *Dispatcher) Dropped() uint64 {
+	return atomic.LoadUint64(&d.dropped)
+}
+
+// Close stops accepting new notifications and waits until every enqueued
+// notification has been sent. If the context is done first, in-flight and
+// still enqueued pushes are cancelled, their results carrying the
+// cancellation error, and the context error is returned. Results which no
+// longer fit in the Results channel are then discarded.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.once.Do(func() {
+		close(d.closing)
+		d.mu.Lock()
+		d.closed = true
+		d.mu.Unlock()
+		close(d.queue)
+	})
+	select {
+	case <-d.finished:
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-d.finished
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+	for n := range d.queue {
+		result := pushResult(d.ctx, d.pusher, n)
+		if d.opts.Callback != nil {
+			d.opts.Callback(result)
+			continue
+		}
+		select {
+		case d.results <- result:
+		default:
+			select {
+			case d.results <- result:
+			case <-d.ctx.Done():
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package apns2_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+func TestDispatcherResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	d := apns.NewDispatcher(mockClient(server.URL), &apns.DispatcherOptions{Workers: 4})
+	done := make(chan int)
+	go func() {
+		count := 0
+		for result := range d.Results() {
+			if result.Err != nil || !result.Response.Sent() {
+				t.Error("Expected notification to be sent, found:", result.Err)
+			}
+			count++
+		}
+		done <- count
+	}()
+	for _, n := range mockNotifications(20) {
+		if err := d.Enqueue(n); err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+	}
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if count := <-done; 20 != count {
+		t.Fatal("Expected:", 20, " found:", count)
+	}
+}
+
+func TestDispatcherCallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	count := 0
+	d := apns.NewDispatcher(mockClient(server.URL), &apns.DispatcherOptions{
+		Callback: func(result apns.PushResult) {
+			mu.Lock()
+			count++
+			mu.Unlock()
+		},
+	})
+	if nil != d.Results() {
+		t.Fatal("Expected nil results channel")
+	}
+	for _, n := range mockNotifications(10) {
+		_ = d.Enqueue(n)
+	}
+	_ = d.Close(context.Background())
+	if 10 != count {
+		t.Fatal("Expected:", 10, " found:", count)
+	}
+}
+
+func TestDispatcherEnqueueAfterClose(t *testing.T) {
+	d := apns.NewDispatcher(mockClient(""), nil)
+	_ = d.Close(context.Background())
+	if err := d.Enqueue(mockNotification()); !errors.Is(err, apns.ErrDispatcherClosed) {
+		t.Fatal("Expected:", apns.ErrDispatcherClosed, " found:", err)
+	}
+}
+
+func mockBlockedDispatcher(t *testing.T, backpressure apns.BackpressurePolicy) (*apns.Dispatcher, chan struct{}, func()) {
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case arrived <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	d := apns.NewDispatcher(mockClient(server.URL), &apns.DispatcherOptions{
+		Workers:      1,
+		QueueSize:    1,
+		Backpressure: backpressure,
+		Callback:     func(apns.PushResult) {},
+	})
+	if err := d.Enqueue(mockNotification()); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	<-arrived
+	if err := d.Enqueue(mockNotification()); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	return d, release, server.Close
+}
+
+func TestDispatcherBackpressureError(t *testing.T) {
+	d, release, closeServer := mockBlockedDispatcher(t, apns.BackpressureError)
+	defer closeServer()
+	if err := d.Enqueue(mockNotification()); !errors.Is(err, apns.ErrQueueFull) {
+		t.Fatal("Expected:", apns.ErrQueueFull, " found:", err)
+	}
+	close(release)
+	_ = d.Close(context.Background())
+}
+
+func TestDispatcherBackpressureDrop(t *testing.T) {
+	d, release, closeServer := mockBlockedDispatcher(t, apns.BackpressureDrop)
+	defer closeServer()
+	if err := d.Enqueue(mockNotification()); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if 1 != d.Dropped() {
+		t.Fatal("Expected:", 1, " found:", d.Dropped())
+	}
+	close(release)
+	_ = d.Close(context.Background())
+}
+
+func TestDispatcherBackpressureBlock(t *testing.T) {
+	d, release, closeServer := mockBlockedDispatcher(t, apns.BackpressureBlock)
+	defer closeServer()
+	enqueued := make(chan error)
+	go func() {
+		enqueued <- d.Enqueue(mockNotification())
+	}()
+	select {
+	case <-enqueued:
+		t.Fatal("Expected Enqueue to block")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := <-enqueued; err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	_ = d.Close(context.Background())
+}
+
+func TestDispatcherCloseTimeout(t *testing.T) {
+	d, release, closeServer := mockBlockedDispatcher(t, apns.BackpressureBlock)
+	defer closeServer()
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Expected:", context.DeadlineExceeded, " found:", err)
+	}
+}
+
+func TestDispatcherCloseTimeoutUnreadResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	d := apns.NewDispatcher(mockClient(server.URL), &apns.DispatcherOptions{Workers: 1, QueueSize: 1})
+	for i := 0; i < 3; i++ {
+		if err := d.Enqueue(mockNotification()); err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	closed := make(chan error)
+	go func() { closed <- d.Close(ctx) }()
+	select {
+	case err := <-closed:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("Expected:", context.DeadlineExceeded, " found:", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Close to return once the context is done")
+	}
+}
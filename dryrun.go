@@ -0,0 +1,95 @@
+package apns2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DryRun configures a Client to marshal, validate and build every request as
+// it would be sent to APNs, including the provider token, and then answer it
+// with a synthetic response instead of contacting APNs.
+type DryRun struct {
+	// StatusCode and Reason of the synthetic responses. If StatusCode is zero,
+	// every notification is accepted with StatusSent.
+	StatusCode int
+	Reason     string
+
+	// Sink, if set, receives every request which would have been sent, along
+	// with its body. It is called concurrently by the pushing goroutines.
+	Sink func(req *http.Request, body []byte)
+}
+
+// RequestWriter returns a DryRun Sink writing every request to w as text: the
+// method and URL, the headers sorted by name and the body, followed by an
+// empty line. The output is meant to be diffed across environments.
+func RequestWriter(w io.Writer) func(req *http.Request, body []byte) {
+	var mu sync.Mutex
+	return func(req *http.Request, body []byte) {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "%v %v\n", req.Method, req.URL)
+		names := make([]string, 0, len(req.Header))
+		for name := range req.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range req.Header[name] {
+				fmt.Fprintf(&buf, "%v: %v\n", name, value)
+			}
+		}
+		buf.WriteString("\n")
+		buf.Write(body)
+		buf.WriteString("\n\n")
+
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// roundTrip hands the request to the Sink and returns the synthetic response.
+func (d *DryRun) roundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if d.Sink != nil {
+		d.Sink(req, body)
+	}
+
+	apnsID := req.Header.Get("apns-id")
+	if apnsID == "" {
+		apnsID = NewApnsID()
+	}
+	res := &http.Response{
+		StatusCode: d.StatusCode,
+		Header:     http.Header{"Apns-Id": []string{apnsID}},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+	if res.StatusCode == 0 {
+		res.StatusCode = StatusSent
+	}
+	if res.StatusCode != StatusSent {
+		errorBody := map[string]interface{}{"reason": d.Reason}
+		if res.StatusCode == http.StatusGone {
+			errorBody["timestamp"] = time.Now().UnixNano() / int64(time.Millisecond)
+		}
+		encoded, err := json.Marshal(errorBody)
+		if err != nil {
+			return nil, err
+		}
+		res.Body = ioutil.NopCloser(bytes.NewReader(encoded))
+	}
+	return res, nil
+}
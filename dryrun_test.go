@@ -0,0 +1,113 @@
+package apns2_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+func TestDryRun(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*http.Request
+	var bodies []string
+	client := apns.NewTokenClient(mockToken())
+	client.HTTPClient = nil
+	client.DryRun = &apns.DryRun{
+		Sink: func(req *http.Request, body []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			requests = append(requests, req)
+			bodies = append(bodies, string(body))
+		},
+	}
+
+	n := mockNotification()
+	n.Topic = "com.testapp"
+	n.Priority = apns.PriorityLow
+	res, err := client.Push(n)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if !res.Sent() || 36 != len(res.ApnsID) {
+		t.Fatal("Expected notification to be sent with a generated apns-id, found:", res.StatusCode, res.ApnsID)
+	}
+	if 1 != len(requests) {
+		t.Fatal("Expected:", 1, " found:", len(requests))
+	}
+	req := requests[0]
+	if apns.HostDevelopment+"/3/device/"+n.DeviceToken != req.URL.String() {
+		t.Fatal("Unexpected URL:", req.URL)
+	}
+	if !strings.HasPrefix(req.Header.Get("authorization"), "bearer ") {
+		t.Fatal("Expected bearer authorization, found:", req.Header.Get("authorization"))
+	}
+	if "com.testapp" != req.Header.Get("apns-topic") || "5" != req.Header.Get("apns-priority") {
+		t.Fatal("Unexpected headers:", req.Header)
+	}
+	if string(n.Payload.([]byte)) != bodies[0] {
+		t.Fatal("Expected:", string(n.Payload.([]byte)), " found:", bodies[0])
+	}
+
+	n.ApnsID = "84DB694F-464F-49BD-960A-D6DB028335C9"
+	if res, _ := client.Push(n); n.ApnsID != res.ApnsID {
+		t.Fatal("Expected:", n.ApnsID, " found:", res.ApnsID)
+	}
+}
+
+func TestDryRunRejection(t *testing.T) {
+	feedback := apns.NewMemoryTokenFeedback()
+	client := mockClient("https://localhost:1")
+	client.TokenFeedback = feedback
+	client.DryRun = &apns.DryRun{StatusCode: http.StatusGone, Reason: apns.ReasonUnregistered}
+
+	res, err := client.Push(mockNotification())
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if apns.ReasonUnregistered != res.Reason || http.StatusGone != res.StatusCode || res.Timestamp.IsZero() {
+		t.Fatal("Unexpected response:", res.StatusCode, res.Reason, res.Timestamp)
+	}
+	if 1 != len(feedback.Tokens()) {
+		t.Fatal("Expected:", 1, " found:", len(feedback.Tokens()))
+	}
+}
+
+func TestDryRunValidates(t *testing.T) {
+	client := mockClient("https://localhost:1")
+	client.DryRun = &apns.DryRun{}
+	n := mockNotification()
+	n.CollapseID = strings.Repeat("c", 65)
+	if _, err := client.Push(n); !errors.Is(err, apns.ErrBadCollapseID) {
+		t.Fatal("Expected:", apns.ErrBadCollapseID, " found:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.PushWithContext(ctx, mockNotification()); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected:", context.Canceled, " found:", err)
+	}
+}
+
+func TestRequestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	client := mockClient("https://localhost:1")
+	client.DryRun = &apns.DryRun{Sink: apns.RequestWriter(&buf)}
+	n := mockNotification()
+	n.Topic = "com.testapp"
+	_, _ = client.Push(n)
+
+	expected := "POST https://localhost:1/3/device/" + n.DeviceToken + "\n" +
+		"Apns-Push-Type: alert\n" +
+		"Apns-Topic: com.testapp\n" +
+		"Content-Type: application/json; charset=utf-8\n" +
+		"\n" + string(n.Payload.([]byte)) + "\n\n"
+	if expected != buf.String() {
+		t.Fatal("Expected:", expected, " found:", buf.String())
+	}
+}
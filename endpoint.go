@@ -0,0 +1,62 @@
+package apns2
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sync/atomic"
+)
+
+// Apple HTTP/2 Development & Production urls on the alternate port 2197, for
+// networks where outbound connections to port 443 are blocked.
+const (
+	HostDevelopmentAlternate = "https://api.sandbox.push.apple.com:2197"
+	HostProductionAlternate  = "https://api.push.apple.com:2197"
+)
+
+// AlternatePort sets the Client to use the alternate port 2197 of its Host,
+// the development or production one alike.
+func (c *Client) AlternatePort() *Client {
+	c.Host = alternateHost(c.Host)
+	return c
+}
+
+// alternateHost returns the host with the alternate port 2197, or the host as
+// is if it is not a valid URL.
+func alternateHost(host string) string {
+	u, err := url.Parse(host)
+	if err != nil || u.Host == "" {
+		return host
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), "2197")
+	return u.String()
+}
+
+// fallback sends the notification to the endpoint currently in use, among the
+// Host and the FallbackHosts. When no connection can be established with it,
+// the next endpoints are tried in order and the first one working is used
+// from then on.
+func (c *Client) fallback(ctx Context, n *Notification, payload []byte, bearer string) (*Response, error) {
+	if len(c.FallbackHosts) == 0 {
+		return c.replay(ctx, n, payload, bearer, c.Host)
+	}
+	hosts := append([]string{c.Host}, c.FallbackHosts...)
+	current := atomic.LoadInt32(&c.endpoint)
+	for i := 0; ; i++ {
+		index := (int(current) + i) % len(hosts)
+		response, err := c.replay(ctx, n, payload, bearer, hosts[index])
+		if err == nil {
+			atomic.CompareAndSwapInt32(&c.endpoint, current, int32(index))
+			return response, nil
+		}
+		if i == len(hosts)-1 || !unreachable(err) || (ctx != nil && ctx.Err() != nil) {
+			return response, err
+		}
+	}
+}
+
+// unreachable reports whether err means that no connection could be
+// established with the endpoint.
+func unreachable(err error) bool {
+	return errors.Is(err, ErrDial) || errors.Is(err, ErrTLSHandshake)
+}
@@ -0,0 +1,92 @@
+package apns2_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/apns2test"
+)
+
+func TestAlternatePort(t *testing.T) {
+	client := apns.NewClient(tls.Certificate{})
+	if apns.HostProductionAlternate != client.Production().AlternatePort().Host {
+		t.Fatal("Expected:", apns.HostProductionAlternate, " found:", client.Host)
+	}
+	if apns.HostDevelopmentAlternate != client.Development().AlternatePort().Host {
+		t.Fatal("Expected:", apns.HostDevelopmentAlternate, " found:", client.Host)
+	}
+	if apns.HostDevelopmentAlternate != client.AlternatePort().Host {
+		t.Fatal("Expected:", apns.HostDevelopmentAlternate, " found:", client.Host)
+	}
+
+	client, err := apns.NewClientWithOptions(apns.WithAlternatePort(), apns.WithToken(mockToken()), apns.WithHost(apns.HostProduction))
+	if err != nil || apns.HostProductionAlternate != client.Host {
+		t.Fatal("Expected:", apns.HostProductionAlternate, " found:", client, err)
+	}
+}
+
+func TestResponseHost(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	res, err := server.Client(apns2test.NewCertificate()).Push(mockNotification())
+	if err != nil || server.URL != res.Host {
+		t.Fatal("Expected:", server.URL, " found:", res, err)
+	}
+}
+
+func TestFallbackHosts(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+
+	// The primary endpoint accepts connections but fails every handshake.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	var dials int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&dials, 1)
+			conn.Close()
+		}
+	}()
+
+	client := server.Client(apns2test.NewCertificate())
+	client.Host = "https://" + listener.Addr().String()
+	client.FallbackHosts = []string{"https://127.0.0.1:1", server.URL}
+	for i := 0; i < 3; i++ {
+		res, err := client.Push(mockNotification())
+		if err != nil || !res.Sent() {
+			t.Fatal("Expected notification to be sent, found:", err)
+		}
+		if server.URL != res.Host {
+			t.Fatal("Expected:", server.URL, " found:", res.Host)
+		}
+	}
+	if 1 != atomic.LoadInt32(&dials) {
+		t.Fatal("Expected the fallback to be sticky, found dials:", atomic.LoadInt32(&dials))
+	}
+}
+
+func TestFallbackHostsUnreachable(t *testing.T) {
+	client := apns.NewClient(tls.Certificate{})
+	client.Host = "https://127.0.0.1:1"
+	client.FallbackHosts = []string{"https://127.0.0.1:2"}
+	if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrDial) {
+		t.Fatal("Expected:", apns.ErrDial, " found:", err)
+	}
+
+	_, err := apns.NewClientWithOptions(apns.WithToken(mockToken()), apns.WithFallbackHosts("api.push.apple.com"))
+	if err == nil {
+		t.Fatal("Expected error for an invalid fallback host")
+	}
+}
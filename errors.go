@@ -0,0 +1,187 @@
+package apns2
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+// Sentinel errors matching each APNs Reason, to be used with errors.Is on the
+// error returned by Response.Err.
+var (
+	ErrBadCollapseID               = errors.New("apns2: " + ReasonBadCollapseID)
+	ErrBadDeviceToken              = errors.New("apns2: " + ReasonBadDeviceToken)
+	ErrBadExpirationDate           = errors.New("apns2: " + ReasonBadExpirationDate)
+	ErrBadMessageID                = errors.New("apns2: " + ReasonBadMessageID)
+	ErrBadPriority                 = errors.New("apns2: " + ReasonBadPriority)
+	ErrBadTopic                    = errors.New("apns2: " + ReasonBadTopic)
+	ErrDeviceTokenNotForTopic      = errors.New("apns2: " + ReasonDeviceTokenNotForTopic)
+	ErrDuplicateHeaders            = errors.New("apns2: " + ReasonDuplicateHeaders)
+	ErrIdleTimeout                 = errors.New("apns2: " + ReasonIdleTimeout)
+	ErrMissingDeviceToken          = errors.New("apns2: " + ReasonMissingDeviceToken)
+	ErrMissingTopic                = errors.New("apns2: " + ReasonMissingTopic)
+	ErrPayloadEmpty                = errors.New("apns2: " + ReasonPayloadEmpty)
+	ErrTopicDisallowed             = errors.New("apns2: " + ReasonTopicDisallowed)
+	ErrBadCertificate              = errors.New("apns2: " + ReasonBadCertificate)
+	ErrBadCertificateEnvironment   = errors.New("apns2: " + ReasonBadCertificateEnvironment)
+	ErrExpiredProviderToken        = errors.New("apns2: " + ReasonExpiredProviderToken)
+	ErrForbidden                   = errors.New("apns2: " + ReasonForbidden)
+	ErrInvalidProviderToken        = errors.New("apns2: " + ReasonInvalidProviderToken)
+	ErrMissingProviderToken        = errors.New("apns2: " + ReasonMissingProviderToken)
+	ErrBadPath                     = errors.New("apns2: " + ReasonBadPath)
+	ErrMethodNotAllowed            = errors.New("apns2: " + ReasonMethodNotAllowed)
+	ErrUnregistered                = errors.New("apns2: " + ReasonUnregistered)
+	ErrPayloadTooLarge             = errors.New("apns2: " + ReasonPayloadTooLarge)
+	ErrTooManyProviderTokenUpdates = errors.New("apns2: " + ReasonTooManyProviderTokenUpdates)
+	ErrTooManyRequests             = errors.New("apns2: " + ReasonTooManyRequests)
+	ErrInternalServerError         = errors.New("apns2: " + ReasonInternalServerError)
+	ErrServiceUnavailable          = errors.New("apns2: " + ReasonServiceUnavailable)
+	ErrShutdown                    = errors.New("apns2: " + ReasonShutdown)
+)
+
+var reasonErrors = map[string]error{
+	ReasonBadCollapseID:               ErrBadCollapseID,
+	ReasonBadDeviceToken:              ErrBadDeviceToken,
+	ReasonBadExpirationDate:           ErrBadExpirationDate,
+	ReasonBadMessageID:                ErrBadMessageID,
+	ReasonBadPriority:                 ErrBadPriority,
+	ReasonBadTopic:                    ErrBadTopic,
+	ReasonDeviceTokenNotForTopic:      ErrDeviceTokenNotForTopic,
+	ReasonDuplicateHeaders:            ErrDuplicateHeaders,
+	ReasonIdleTimeout:                 ErrIdleTimeout,
+	ReasonMissingDeviceToken:          ErrMissingDeviceToken,
+	ReasonMissingTopic:                ErrMissingTopic,
+	ReasonPayloadEmpty:                ErrPayloadEmpty,
+	ReasonTopicDisallowed:             ErrTopicDisallowed,
+	ReasonBadCertificate:              ErrBadCertificate,
+	ReasonBadCertificateEnvironment:   ErrBadCertificateEnvironment,
+	ReasonExpiredProviderToken:        ErrExpiredProviderToken,
+	ReasonForbidden:                   ErrForbidden,
+	ReasonInvalidProviderToken:        ErrInvalidProviderToken,
+	ReasonMissingProviderToken:        ErrMissingProviderToken,
+	ReasonBadPath:                     ErrBadPath,
+	ReasonMethodNotAllowed:            ErrMethodNotAllowed,
+	ReasonUnregistered:                ErrUnregistered,
+	ReasonPayloadTooLarge:             ErrPayloadTooLarge,
+	ReasonTooManyProviderTokenUpdates: ErrTooManyProviderTokenUpdates,
+	ReasonTooManyRequests:             ErrTooManyRequests,
+	ReasonInternalServerError:         ErrInternalServerError,
+	ReasonServiceUnavailable:          ErrServiceUnavailable,
+	ReasonShutdown:                    ErrShutdown,
+}
+
+// APNsError describes a notification rejected by APNs. It is returned by
+// Response.Err and matches, with errors.Is, the sentinel error of its Reason.
+type APNsError struct {
+	StatusCode int
+	Reason     string
+	ApnsID     string
+	Timestamp  Time
+}
+
+func (e *APNsError) Error() string {
+	return fmt.Sprintf("apns2: notification %v rejected with status %v: %v", e.ApnsID, e.StatusCode, e.Reason)
+}
+
+// Unwrap returns the sentinel error of the Reason, or nil if the Reason is
+// unknown.
+func (e *APNsError) Unwrap() error {
+	return reasonErrors[e.Reason]
+}
+
+// Kinds of TransportError, to be used with errors.Is on the errors returned by
+// the Client when no response was received from APNs.
+var (
+	ErrDial         = errors.New("apns2: dial failed")
+	ErrTLSHandshake = errors.New("apns2: tls handshake failed")
+	ErrStreamReset  = errors.New("apns2: stream reset by peer")
+	ErrGoAway       = errors.New("apns2: connection closed by GOAWAY")
+	ErrTimeout      = errors.New("apns2: timeout")
+	ErrConnLost     = errors.New("apns2: connection lost")
+)
+
+// TransportError describes a failure to deliver a notification to APNs at the
+// connection level. Kind is one of ErrDial, ErrTLSHandshake, ErrStreamReset,
+// ErrGoAway, ErrTimeout or ErrConnLost and Err is the underlying error.
+type TransportError struct {
+	Kind error
+	Err  error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the Kind of the error. Any error caused by a
+// timeout also matches ErrTimeout.
+func (e *TransportError) Is(target error) bool {
+	return target == e.Kind || (target == ErrTimeout && isTimeout(e.Err))
+}
+
+// transportError wraps an error returned by the http.Client into a
+// TransportError, if its kind can be recognized.
+func transportError(err error) error {
+	var te *TransportError
+	if errors.As(err, &te) {
+		return err
+	}
+	if kind := transportErrorKind(err); kind != nil {
+		return &TransportError{Kind: kind, Err: err}
+	}
+	return err
+}
+
+func transportErrorKind(err error) error {
+	var (
+		goAway       http2.GoAwayError
+		streamErr    http2.StreamError
+		opErr        *net.OpError
+		recordErr    tls.RecordHeaderError
+		authorityErr x509.UnknownAuthorityError
+		invalidErr   x509.CertificateInvalidError
+		hostErr      x509.HostnameError
+	)
+	switch {
+	case errors.As(err, &goAway), strings.Contains(err.Error(), "GOAWAY"):
+		return ErrGoAway
+	case errors.As(err, &streamErr):
+		return ErrStreamReset
+	case errors.As(err, &recordErr), errors.As(err, &authorityErr), errors.As(err, &invalidErr), errors.As(err, &hostErr):
+		return ErrTLSHandshake
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return ErrDial
+	case isTimeout(err):
+		return ErrTimeout
+	case strings.Contains(err.Error(), "client connection lost"):
+		return ErrConnLost
+	}
+	return nil
+}
+
+// unprocessed reports whether err guarantees that APNs did not process the
+// request: its stream was above the last stream ID of a GOAWAY frame or was
+// refused, or the connection was unusable.
+func unprocessed(err error) bool {
+	var streamErr http2.StreamError
+	if errors.As(err, &streamErr) {
+		return streamErr.Code == http2.ErrCodeRefusedStream
+	}
+	message := err.Error()
+	return strings.Contains(message, "graceful shutdown GOAWAY") || strings.Contains(message, "client conn not usable")
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
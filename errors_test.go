@@ -0,0 +1,125 @@
+package apns2_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+func TestResponseErrSent(t *testing.T) {
+	if err := (&apns.Response{StatusCode: 200}).Err(); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+}
+
+func TestResponseErr(t *testing.T) {
+	res := &apns.Response{StatusCode: 410, Reason: apns.ReasonUnregistered, ApnsID: "9F595474-356C-485E-B67F-9870BAE68702"}
+	res.Timestamp.Time = time.Unix(1458114061, 0)
+	err := res.Err()
+	if !errors.Is(err, apns.ErrUnregistered) {
+		t.Fatal("Expected:", apns.ErrUnregistered, " found:", err)
+	}
+	if errors.Is(err, apns.ErrBadDeviceToken) {
+		t.Fatal("Expected error not to match", apns.ErrBadDeviceToken)
+	}
+	var apnsErr *apns.APNsError
+	if !errors.As(err, &apnsErr) {
+		t.Fatal("Expected an *APNsError, found:", err)
+	}
+	if 410 != apnsErr.StatusCode || res.ApnsID != apnsErr.ApnsID || !res.Timestamp.Equal(apnsErr.Timestamp.Time) {
+		t.Fatal("Expected:", res, " found:", apnsErr)
+	}
+}
+
+func TestResponseErrUnknownReason(t *testing.T) {
+	err := (&apns.Response{StatusCode: 400, Reason: "SomethingNew"}).Err()
+	var apnsErr *apns.APNsError
+	if !errors.As(err, &apnsErr) || "SomethingNew" != apnsErr.Reason {
+		t.Fatal("Expected an *APNsError, found:", err)
+	}
+	if nil != errors.Unwrap(err) {
+		t.Fatal("Expected no sentinel error, found:", errors.Unwrap(err))
+	}
+}
+
+func TestTransportErrorIs(t *testing.T) {
+	err := &apns.TransportError{Kind: apns.ErrDial, Err: context.DeadlineExceeded}
+	if !errors.Is(err, apns.ErrDial) {
+		t.Fatal("Expected error to match", apns.ErrDial)
+	}
+	if !errors.Is(err, apns.ErrTimeout) {
+		t.Fatal("Expected error to match", apns.ErrTimeout)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Expected error to match", context.DeadlineExceeded)
+	}
+	if errors.Is(err, apns.ErrGoAway) {
+		t.Fatal("Expected error not to match", apns.ErrGoAway)
+	}
+}
+
+func TestClientDialError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	client := apns.NewClient(mockCert())
+	client.Host = "https://" + address
+	_, err = client.Push(mockNotification())
+	if !errors.Is(err, apns.ErrDial) {
+		t.Fatal("Expected:", apns.ErrDial, " found:", err)
+	}
+	var transportErr *apns.TransportError
+	if !errors.As(err, &transportErr) {
+		t.Fatal("Expected a *TransportError, found:", err)
+	}
+}
+
+func TestClientTLSHandshakeError(t *testing.T) {
+	server := mockHTTP2Server(func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Close()
+
+	client := apns.NewClient(mockCert())
+	client.Host = server.URL
+	_, err := client.Push(mockNotification())
+	if !errors.Is(err, apns.ErrTLSHandshake) {
+		t.Fatal("Expected:", apns.ErrTLSHandshake, " found:", err)
+	}
+}
+
+func TestClientStreamResetError(t *testing.T) {
+	server := mockHTTP2Server(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	defer server.Close()
+
+	_, err := mockHTTP2Client(server).Push(mockNotification())
+	if !errors.Is(err, apns.ErrStreamReset) {
+		t.Fatal("Expected:", apns.ErrStreamReset, " found:", err)
+	}
+}
+
+func TestClientTimeoutError(t *testing.T) {
+	server := mockHTTP2Server(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := mockHTTP2Client(server).PushWithContext(ctx, mockNotification())
+	if !errors.Is(err, apns.ErrTimeout) {
+		t.Fatal("Expected:", apns.ErrTimeout, " found:", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Expected:", context.DeadlineExceeded, " found:", err)
+	}
+}
@@ -0,0 +1,78 @@
+package apns2
+
+import (
+	"sort"
+	"sync"
+)
+
+// DeadToken describes a device token which APNs reported as no longer valid
+// for a topic, either because the app was uninstalled (Unregistered) or
+// because the token is malformed or belongs to another topic (BadDeviceToken,
+// DeviceTokenNotForTopic).
+type DeadToken struct {
+	DeviceToken string
+	Topic       string
+	Reason      string
+
+	// For Unregistered tokens, the last time APNs confirmed that the device
+	// token was no longer valid for the topic. Zero otherwise.
+	Timestamp Time
+}
+
+// TokenFeedback receives the device tokens which should be removed from the
+// provider database. Report is called synchronously by the Client goroutine
+// which sent the notification, so implementations must be safe for concurrent
+// use and should not block.
+type TokenFeedback interface {
+	Report(token DeadToken)
+}
+
+// MemoryTokenFeedback is a TokenFeedback keeping the dead tokens in memory,
+// keyed by device token.
+type MemoryTokenFeedback struct {
+	mu     sync.Mutex
+	tokens map[string]DeadToken
+}
+
+// NewMemoryTokenFeedback returns a new, empty MemoryTokenFeedback.
+func NewMemoryTokenFeedback() *MemoryTokenFeedback {
+	return &MemoryTokenFeedback{tokens: map[string]DeadToken{}}
+}
+
+// Report records the dead token, replacing any previous report for the same
+// device token.
+func (f *MemoryTokenFeedback) Report(token DeadToken) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[token.DeviceToken] = token
+}
+
+// Tokens returns the reported dead tokens, sorted by device token.
+func (f *MemoryTokenFeedback) Tokens() []DeadToken {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens := make([]DeadToken, 0, len(f.tokens))
+	for _, token := range f.tokens {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].DeviceToken < tokens[j].DeviceToken
+	})
+	return tokens
+}
+
+// Lookup returns the report for the device token, if any.
+func (f *MemoryTokenFeedback) Lookup(deviceToken string) (DeadToken, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[deviceToken]
+	return token, ok
+}
+
+// Remove forgets the report for the device token, typically once it has been
+// deleted from the provider database.
+func (f *MemoryTokenFeedback) Remove(deviceToken string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.tokens, deviceToken)
+}
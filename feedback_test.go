@@ -0,0 +1,107 @@
+package apns2_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+func mockFeedbackServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/3/device/unregistered":
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte("{\"reason\":\"Unregistered\", \"timestamp\": 1458114061260 }"))
+		case "/3/device/bad":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("{\"reason\":\"BadDeviceToken\"}"))
+		case "/3/device/othertopic":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("{\"reason\":\"DeviceTokenNotForTopic\"}"))
+		case "/3/device/throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("{\"reason\":\"TooManyRequests\"}"))
+		}
+	}))
+}
+
+func TestTokenFeedback(t *testing.T) {
+	server := mockFeedbackServer()
+	defer server.Close()
+
+	feedback := apns.NewMemoryTokenFeedback()
+	client := mockClient(server.URL)
+	client.TokenFeedback = feedback
+	for _, token := range []string{"unregistered", "bad", "othertopic", "throttled", "valid"} {
+		n := mockNotification()
+		n.DeviceToken = token
+		n.Topic = "com.testapp"
+		if _, err := client.Push(n); err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+	}
+
+	tokens := feedback.Tokens()
+	if 3 != len(tokens) {
+		t.Fatal("Expected:", 3, " found:", tokens)
+	}
+	expected := []struct{ token, reason string }{
+		{"bad", apns.ReasonBadDeviceToken},
+		{"othertopic", apns.ReasonDeviceTokenNotForTopic},
+		{"unregistered", apns.ReasonUnregistered},
+	}
+	for i, e := range expected {
+		if e.token != tokens[i].DeviceToken || e.reason != tokens[i].Reason {
+			t.Fatal("Expected:", e.token, e.reason, " found:", tokens[i].DeviceToken, tokens[i].Reason)
+		}
+		if "com.testapp" != tokens[i].Topic {
+			t.Fatal("Expected:", "com.testapp", " found:", tokens[i].Topic)
+		}
+	}
+
+	unregistered, ok := feedback.Lookup("unregistered")
+	if !ok {
+		t.Fatal("Expected unregistered token to be reported")
+	}
+	if int64(1458114061260)/1000 != unregistered.Timestamp.Unix() {
+		t.Fatal("Expected:", int64(1458114061260)/1000, " found:", unregistered.Timestamp.Unix())
+	}
+	if !tokens[0].Timestamp.IsZero() {
+		t.Fatal("Expected zero timestamp, found:", tokens[0].Timestamp)
+	}
+}
+
+func TestTokenFeedbackMulticast(t *testing.T) {
+	server := mockFeedbackServer()
+	defer server.Close()
+
+	feedback := apns.NewMemoryTokenFeedback()
+	client := mockClient(server.URL)
+	client.TokenFeedback = feedback
+	m := &apns.Multicast{Topic: "com.testapp", Payload: []byte(`{}`)}
+	if _, err := client.PushMulticast(context.Background(), m, []string{"unregistered", "valid"}, nil); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if _, ok := feedback.Lookup("unregistered"); !ok {
+		t.Fatal("Expected unregistered token to be reported")
+	}
+	if _, ok := feedback.Lookup("valid"); ok {
+		t.Fatal("Expected valid token not to be reported")
+	}
+}
+
+func TestMemoryTokenFeedbackRemove(t *testing.T) {
+	feedback := apns.NewMemoryTokenFeedback()
+	feedback.Report(apns.DeadToken{DeviceToken: "a", Reason: apns.ReasonUnregistered})
+	feedback.Report(apns.DeadToken{DeviceToken: "a", Reason: apns.ReasonBadDeviceToken})
+	if tokens := feedback.Tokens(); 1 != len(tokens) || apns.ReasonBadDeviceToken != tokens[0].Reason {
+		t.Fatal("Expected the latest report, found:", tokens)
+	}
+	feedback.Remove("a")
+	if tokens := feedback.Tokens(); 0 != len(tokens) {
+		t.Fatal("Expected:", 0, " found:", len(tokens))
+	}
+}
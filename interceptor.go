@@ -0,0 +1,57 @@
+package apns2
+
+import (
+	"context"
+	"net/http"
+)
+
+// PushFunc sends a notification to APNs, returning its Response.
+type PushFunc func(ctx Context, n *Notification) (*Response, error)
+
+// Interceptor wraps the HTTP round trip of a notification. It can inspect the
+// notification and the context, call next to perform the round trip, or
+// return without calling it to short-circuit the request.
+//
+// To alter the notification, an Interceptor passes a modified copy to next:
+// the request is then built again from the copy, discarding the changes made
+// to the previous Exchange Request. Changing the notification in place has
+// no effect on the request.
+//
+// Interceptors run once per request sent to APNs, so a notification retried
+// by the RetryPolicy or resent after a provider token refresh goes through
+// the chain again. The Exchange of the request can be retrieved from the
+// context with ExchangeFromContext.
+type Interceptor func(ctx Context, n *Notification, next PushFunc) (*Response, error)
+
+// Exchange holds the HTTP request of a notification and, once the round trip
+// is done, the raw HTTP response.
+type Exchange struct {
+	// Request is built, headers included, before the interceptors run, and
+	// built again if an interceptor passes another notification to next.
+	Request *http.Request
+
+	// Response is the response received from APNs, nil until next returns or
+	// if no response was received. Its body has already been consumed.
+	Response *http.Response
+}
+
+type exchangeKey struct{}
+
+// ExchangeFromContext returns the Exchange of the request being sent, or nil
+// if ctx is not the context of an Interceptor.
+func ExchangeFromContext(ctx context.Context) *Exchange {
+	exchange, _ := ctx.Value(exchangeKey{}).(*Exchange)
+	return exchange
+}
+
+// chain returns a PushFunc running the Client interceptors, in order, around
+// push.
+func (c *Client) chain(push PushFunc) PushFunc {
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		interceptor, next := c.Interceptors[i], push
+		push = func(ctx Context, n *Notification) (*Response, error) {
+			return interceptor(ctx, n, next)
+		}
+	}
+	return push
+}
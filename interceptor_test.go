@@ -0,0 +1,153 @@
+package apns2_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+func TestInterceptorsOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var calls []string
+	trace := func(name string) apns.Interceptor {
+		return func(ctx apns.Context, n *apns.Notification, next apns.PushFunc) (*apns.Response, error) {
+			calls = append(calls, name+" before")
+			res, err := next(ctx, n)
+			calls = append(calls, name+" after")
+			return res, err
+		}
+	}
+	client := mockClient(server.URL)
+	client.Interceptors = []apns.Interceptor{trace("a"), trace("b")}
+	if _, err := client.Push(mockNotification()); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	expected := []string{"a before", "b before", "b after", "a after"}
+	if len(expected) != len(calls) {
+		t.Fatal("Expected:", expected, " found:", calls)
+	}
+	for i := range expected {
+		if expected[i] != calls[i] {
+			t.Fatal("Expected:", expected, " found:", calls)
+		}
+	}
+}
+
+func TestInterceptorExchange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("apns-unique-id", r.Header.Get("x-request-id"))
+	}))
+	defer server.Close()
+
+	var uniqueID string
+	client := mockClient(server.URL)
+	client.Interceptors = []apns.Interceptor{
+		func(ctx apns.Context, n *apns.Notification, next apns.PushFunc) (*apns.Response, error) {
+			exchange := apns.ExchangeFromContext(ctx)
+			if "com.testapp" != exchange.Request.Header.Get("apns-topic") {
+				t.Error("Expected request headers, found:", exchange.Request.Header)
+			}
+			exchange.Request.Header.Set("x-request-id", "42")
+			res, err := next(ctx, n)
+			uniqueID = exchange.Response.Header.Get("apns-unique-id")
+			return res, err
+		},
+	}
+	n := mockNotification()
+	n.Topic = "com.testapp"
+	if _, err := client.Push(n); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if "42" != uniqueID {
+		t.Fatal("Expected:", "42", " found:", uniqueID)
+	}
+	if nil != apns.ExchangeFromContext(context.Background()) {
+		t.Fatal("Expected nil exchange outside of interceptors")
+	}
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.Interceptors = []apns.Interceptor{
+		func(ctx apns.Context, n *apns.Notification, next apns.PushFunc) (*apns.Response, error) {
+			return &apns.Response{StatusCode: http.StatusTooManyRequests, Reason: apns.ReasonTooManyRequests}, nil
+		},
+	}
+	res, err := client.Push(mockNotification())
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if apns.ReasonTooManyRequests != res.Reason || 0 != atomic.LoadInt32(&requests) {
+		t.Fatal("Expected a short-circuited response, found:", res.Reason, requests)
+	}
+}
+
+func TestInterceptorRunsPerAttempt(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"reason":"ServiceUnavailable"}`))
+		}
+	}))
+	defer server.Close()
+
+	calls := 0
+	client := mockClient(server.URL)
+	client.RetryPolicy = apns.NewRetryPolicy()
+	client.RetryPolicy.InitialBackoff = time.Millisecond
+	client.Interceptors = []apns.Interceptor{
+		func(ctx apns.Context, n *apns.Notification, next apns.PushFunc) (*apns.Response, error) {
+			calls++
+			return next(ctx, n)
+		},
+	}
+	res, err := client.Push(mockNotification())
+	if err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	if 2 != calls {
+		t.Fatal("Expected:", 2, " found:", calls)
+	}
+}
+
+func TestInterceptorModifiedNotification(t *testing.T) {
+	var topic, collapseID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		topic, collapseID = r.Header.Get("apns-topic"), r.Header.Get("apns-collapse-id")
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.Interceptors = []apns.Interceptor{
+		func(ctx apns.Context, n *apns.Notification, next apns.PushFunc) (*apns.Response, error) {
+			modified := *n
+			modified.Topic = "com.testapp.modified"
+			modified.CollapseID = "modified"
+			res, err := next(ctx, &modified)
+			if exchange := apns.ExchangeFromContext(ctx); "modified" != exchange.Request.Header.Get("apns-collapse-id") {
+				t.Error("Expected rebuilt request, found:", exchange.Request.Header)
+			}
+			return res, err
+		},
+	}
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	if "com.testapp.modified" != topic || "modified" != collapseID {
+		t.Fatal("Expected:", "com.testapp.modified", "modified", " found:", topic, collapseID)
+	}
+}
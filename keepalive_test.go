@@ -0,0 +1,74 @@
+package apns2_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/apns2test"
+	"golang.org/x/net/http2"
+)
+
+func TestKeepaliveDefaults(t *testing.T) {
+	transport := apns.NewTokenClient(mockToken()).HTTPClient.Transport.(*http2.Transport)
+	if apns.ReadIdleTimeout != transport.ReadIdleTimeout || apns.PingTimeout != transport.PingTimeout {
+		t.Fatal("Expected health checks, found:", transport.ReadIdleTimeout, transport.PingTimeout)
+	}
+}
+
+func TestKeepaliveDeadConnection(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	logger := &mockLogger{}
+	client := server.Client(apns2test.NewCertificate())
+	client.Logger = logger
+	transport := client.HTTPClient.Transport.(*http2.Transport)
+	transport.ReadIdleTimeout = 20 * time.Millisecond
+	transport.PingTimeout = 20 * time.Millisecond
+
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	// Answered health checks keep the connection open.
+	time.Sleep(100 * time.Millisecond)
+	if 0 != len(logger.find(apns.LogConnectionClosed)) {
+		t.Fatal("Expected connection to stay open, found:", logger.events)
+	}
+
+	server.Blackhole()
+	for i := 0; i < 200 && 0 == len(logger.find(apns.LogConnectionClosed)); i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if 1 != len(logger.find(apns.LogConnectionUnhealthy)) || 1 != len(logger.find(apns.LogConnectionClosed)) {
+		t.Fatal("Expected the unhealthy connection to be closed, found:", logger.events)
+	}
+
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	if 2 != server.Connections() {
+		t.Fatal("Expected:", 2, " found:", server.Connections())
+	}
+}
+
+func TestKeepaliveConnectionLost(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	client := server.Client(apns2test.NewCertificate())
+	transport := client.HTTPClient.Transport.(*http2.Transport)
+	transport.ReadIdleTimeout = 20 * time.Millisecond
+	transport.PingTimeout = 20 * time.Millisecond
+
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	server.Blackhole()
+	start := time.Now()
+	if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrConnLost) {
+		t.Fatal("Expected:", apns.ErrConnLost, " found:", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatal("Expected the dead connection to be detected quickly, found:", elapsed)
+	}
+}
@@ -0,0 +1,193 @@
+package apns2
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Logger receives the structured events of a Client. Events may be logged from
+// the goroutines of the HTTP/2 transport, so implementations must be safe for
+// concurrent use.
+type Logger interface {
+	Log(event LogEvent)
+}
+
+// LogEventType is the type of a LogEvent.
+type LogEventType string
+
+// The types of the events received by a Logger.
+const (
+	// LogRequestSent is logged before a request is sent to APNs.
+	LogRequestSent LogEventType = "request_sent"
+	// LogResponseReceived is logged once the request completes, with the
+	// response or the error.
+	LogResponseReceived LogEventType = "response_received"
+	// LogTokenGenerated is logged when the provider token is regenerated.
+	LogTokenGenerated LogEventType = "token_generated"
+	// LogConnectionOpened is logged when a connection to APNs is established.
+	LogConnectionOpened LogEventType = "connection_opened"
+	// LogConnectionClosed is logged when a connection to APNs is closed.
+	LogConnectionClosed LogEventType = "connection_closed"
+	// LogConnectionUnhealthy is logged when a connection to APNs is closed
+	// because it did not answer a health check PING frame.
+	LogConnectionUnhealthy LogEventType = "connection_unhealthy"
+)
+
+// LogEvent is an event of a Client, redacted according to its LogRedaction.
+// Only the fields relevant to the event Type are set.
+type LogEvent struct {
+	Type LogEventType
+	Time time.Time
+
+	// The notification of request and response events. DeviceToken is
+	// redacted and Payload is nil unless the redaction policy keeps it.
+	DeviceToken string
+	Topic       string
+	ApnsID      string
+	Header      http.Header
+	Payload     []byte
+	PayloadSize int64
+
+	// The outcome of response events.
+	StatusCode int
+	Reason     string
+	Err        error
+
+	// Duration is the duration of the request for response events, of the
+	// dial and TLS handshake for connection opened events and the lifetime
+	// of the connection for connection closed events.
+	Duration time.Duration
+
+	// RemoteAddr is the address of the connection events.
+	RemoteAddr string
+}
+
+// TokenRedaction is how device tokens are redacted from LogEvents.
+type TokenRedaction int
+
+const (
+	// TokenHash replaces device tokens with their SHA-256 hex digest.
+	TokenHash TokenRedaction = iota
+	// TokenTruncate keeps the first 8 characters of device tokens.
+	TokenTruncate
+	// TokenClear logs device tokens as they are.
+	TokenClear
+)
+
+// PayloadRedaction is how payloads are redacted from LogEvents.
+type PayloadRedaction int
+
+const (
+	// PayloadSizeOnly logs only the size of payloads.
+	PayloadSizeOnly PayloadRedaction = iota
+	// PayloadDrop logs neither payloads nor their size.
+	PayloadDrop
+	// PayloadClear logs payloads as they are.
+	PayloadClear
+)
+
+// LogRedaction is the redaction policy of the events of a Client. The zero
+// value hashes device tokens and logs only the size of payloads. The bearer
+// of the authorization header is always masked.
+type LogRedaction struct {
+	DeviceToken TokenRedaction
+	Payload     PayloadRedaction
+}
+
+const maskedBearer = "bearer [REDACTED]"
+
+func hashDeviceToken(deviceToken string) string {
+	hash := sha256.Sum256([]byte(deviceToken))
+	return hex.EncodeToString(hash[:])
+}
+
+func (r LogRedaction) deviceToken(deviceToken string) string {
+	switch r.DeviceToken {
+	case TokenClear:
+		return deviceToken
+	case TokenTruncate:
+		if len(deviceToken) > 8 {
+			return deviceToken[:8] + "..."
+		}
+		return deviceToken
+	}
+	return hashDeviceToken(deviceToken)
+}
+
+// requestEvent returns the event of a request with the redacted notification.
+func (r LogRedaction) requestEvent(eventType LogEventType, n *Notification, req *http.Request) LogEvent {
+	event := LogEvent{
+		Type:        eventType,
+		Time:        time.Now(),
+		DeviceToken: r.deviceToken(n.DeviceToken),
+		Topic:       req.Header.Get("apns-topic"),
+		ApnsID:      req.Header.Get("apns-id"),
+		Header:      req.Header.Clone(),
+	}
+	if event.Header.Get("authorization") != "" {
+		event.Header.Set("authorization", maskedBearer)
+	}
+	switch r.Payload {
+	case PayloadSizeOnly:
+		event.PayloadSize = req.ContentLength
+	case PayloadClear:
+		event.PayloadSize = req.ContentLength
+		if req.GetBody != nil {
+			if body, err := req.GetBody(); err == nil {
+				event.Payload, _ = ioutil.ReadAll(body)
+			}
+		}
+	}
+	return event
+}
+
+func (c *Client) log(event LogEvent) {
+	if c.Logger != nil {
+		c.Logger.Log(event)
+	}
+}
+
+// loggedDial returns a dial function logging the connections dialed by dial.
+// Only the closing of the connections dialed by DialTLS can be logged.
+func (c *Client) loggedDial(dial func(network, addr string, cfg *tls.Config) (net.Conn, error)) func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+	return func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+		start := time.Now()
+		conn, err := dial(network, addr, cfg)
+		if err != nil || c.Logger == nil {
+			return conn, err
+		}
+		opened := time.Now()
+		c.log(LogEvent{
+			Type:       LogConnectionOpened,
+			Time:       opened,
+			Duration:   opened.Sub(start),
+			RemoteAddr: addr,
+		})
+		if tracked, ok := conn.(*trackedConn); ok {
+			tracked.onClose = func(err error) {
+				closed := time.Now()
+				c.log(LogEvent{
+					Type:       LogConnectionClosed,
+					Time:       closed,
+					Duration:   closed.Sub(opened),
+					RemoteAddr: addr,
+					Err:        err,
+				})
+			}
+		}
+		return conn, nil
+	}
+}
+
+// countError receives the errors counted by the HTTP/2 transport, logging the
+// connections closed by a failed health check.
+func (c *Client) countError(errType string) {
+	if errType == "conn_close_lost_ping" {
+		c.log(LogEvent{Type: LogConnectionUnhealthy, Time: time.Now()})
+	}
+}
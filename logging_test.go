@@ -0,0 +1,115 @@
+package apns2_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/apns2test"
+	"golang.org/x/net/http2"
+)
+
+type mockLogger struct {
+	mu     sync.Mutex
+	events []apns.LogEvent
+}
+
+func (l *mockLogger) Log(event apns.LogEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *mockLogger) find(eventType apns.LogEventType) []apns.LogEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var found []apns.LogEvent
+	for _, event := range l.events {
+		if eventType == event.Type {
+			found = append(found, event)
+		}
+	}
+	return found
+}
+
+func TestLoggerEvents(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	logger := &mockLogger{}
+	client := server.TokenClient(apns2test.NewToken())
+	client.Logger = logger
+
+	n := mockNotification()
+	n.Topic = "com.apns2test"
+	n.Payload = []byte(`{"aps":{"alert":"secret"}}`)
+	res, err := client.Push(n)
+	if err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err, res.Reason)
+	}
+	// The stream may not be released yet when Push returns.
+	for i := 0; i < 100 && 0 == len(logger.find(apns.LogConnectionClosed)); i++ {
+		client.HTTPClient.Transport.(*http2.Transport).CloseIdleConnections()
+		time.Sleep(time.Millisecond)
+	}
+
+	hash := sha256.Sum256([]byte(n.DeviceToken))
+	for _, eventType := range []apns.LogEventType{apns.LogRequestSent, apns.LogResponseReceived} {
+		events := logger.find(eventType)
+		if 1 != len(events) {
+			t.Fatal("Expected one", eventType, " found:", logger.events)
+		}
+		event := events[0]
+		if hex.EncodeToString(hash[:]) != event.DeviceToken {
+			t.Fatal("Expected hashed device token, found:", event.DeviceToken)
+		}
+		if "bearer [REDACTED]" != event.Header.Get("authorization") {
+			t.Fatal("Expected masked bearer, found:", event.Header.Get("authorization"))
+		}
+		if nil != event.Payload || int64(len(n.Payload.([]byte))) != event.PayloadSize {
+			t.Fatal("Expected payload size only, found:", string(event.Payload), event.PayloadSize)
+		}
+	}
+	if event := logger.find(apns.LogResponseReceived)[0]; 200 != event.StatusCode || res.ApnsID != event.ApnsID {
+		t.Fatal("Expected response event, found:", event)
+	}
+	if 1 != len(logger.find(apns.LogTokenGenerated)) {
+		t.Fatal("Expected token generated event, found:", logger.events)
+	}
+	if 1 != len(logger.find(apns.LogConnectionOpened)) || 1 != len(logger.find(apns.LogConnectionClosed)) {
+		t.Fatal("Expected connection opened and closed events, found:", logger.events)
+	}
+}
+
+func TestLoggerRedaction(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	logger := &mockLogger{}
+	client := server.Client(apns2test.NewCertificate())
+	client.Logger = logger
+
+	n := mockNotification()
+	n.Payload = []byte(`{"aps":{"alert":"secret"}}`)
+	client.LogRedaction = apns.LogRedaction{DeviceToken: apns.TokenTruncate, Payload: apns.PayloadDrop}
+	if _, err := client.Push(n); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	event := logger.find(apns.LogRequestSent)[0]
+	if n.DeviceToken[:8]+"..." != event.DeviceToken {
+		t.Fatal("Expected truncated device token, found:", event.DeviceToken)
+	}
+	if nil != event.Payload || 0 != event.PayloadSize {
+		t.Fatal("Expected dropped payload, found:", string(event.Payload), event.PayloadSize)
+	}
+
+	client.LogRedaction = apns.LogRedaction{DeviceToken: apns.TokenClear, Payload: apns.PayloadClear}
+	if _, err := client.Push(n); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	event = logger.find(apns.LogRequestSent)[1]
+	if n.DeviceToken != event.DeviceToken || string(n.Payload.([]byte)) != string(event.Payload) {
+		t.Fatal("Expected clear device token and payload, found:", event.DeviceToken, string(event.Payload))
+	}
+}
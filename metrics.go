@@ -0,0 +1,42 @@
+package apns2
+
+import "time"
+
+// Metrics receives measurements from a Client or a ClientManager. Its methods
+// are called concurrently by the pushing goroutines and should not block.
+type Metrics interface {
+	// RequestStarted is called when a request is sent to APNs. Together with
+	// RequestFinished, it tracks the number of streams in flight.
+	RequestStarted()
+
+	// RequestFinished is called once the request ends, whether a response
+	// was received or not.
+	RequestFinished(r RequestMetrics)
+
+	// TokenGenerated is called every time the Client generates a new
+	// provider token.
+	TokenGenerated()
+
+	// ClientEvicted is called every time the ClientManager evicts a Client,
+	// because it is full or because the Client is too old.
+	ClientEvicted()
+}
+
+// RequestMetrics describes a request sent to APNs.
+type RequestMetrics struct {
+	Topic    string
+	PushType EPushType
+
+	// StatusCode and Reason of the response. StatusCode is zero and Err is
+	// set if no response was received.
+	StatusCode int
+	Reason     string
+	Err        error
+
+	// Duration is the time elapsed between sending the request and decoding
+	// the response.
+	Duration time.Duration
+
+	// BytesSent is the size of the payload.
+	BytesSent int64
+}
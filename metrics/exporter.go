@@ -0,0 +1,233 @@
+// Package metrics exposes the measurements of apns2 clients in the Prometheus
+// text exposition format, without depending on the Prometheus client library.
+//
+//	exporter := metrics.NewExporter()
+//	client.Metrics = exporter
+//	http.Handle("/metrics", exporter)
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the request duration
+// histogram buckets.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Exporter is an apns2.Metrics collecting the measurements of any number of
+// clients and managers. It implements http.Handler, serving them in the
+// Prometheus text exposition format.
+type Exporter struct {
+	// Namespace prefixes the name of every metric. If empty, "apns2" is used.
+	Namespace string
+
+	// Buckets are the upper bounds of the request duration histogram. If
+	// nil, DefaultBuckets is used. Must not be changed once the Exporter is
+	// in use.
+	Buckets []float64
+
+	mu          sync.Mutex
+	pushes      map[pushKey]uint64
+	counts      []uint64
+	sum         float64
+	total       uint64
+	inFlight    int64
+	bytesSent   int64
+	tokens      uint64
+	evictions   uint64
+	initBuckets sync.Once
+}
+
+type pushKey struct {
+	status   string
+	reason   string
+	topic    string
+	pushType string
+}
+
+var _ apns.Metrics = (*Exporter)(nil)
+
+// NewExporter returns a new Exporter with the default namespace and buckets.
+func NewExporter() *Exporter {
+	return &Exporter{}
+}
+
+// RequestStarted increments the number of requests in flight.
+func (e *Exporter) RequestStarted() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.inFlight++
+}
+
+// RequestFinished records the outcome, duration and size of the request.
+func (e *Exporter) RequestFinished(r apns.RequestMetrics) {
+	e.init()
+	key := pushKey{
+		status:   strconv.Itoa(r.StatusCode),
+		reason:   r.Reason,
+		topic:    r.Topic,
+		pushType: string(r.PushType),
+	}
+	if r.Err != nil {
+		key.status = "error"
+		key.reason = errorKind(r.Err)
+	}
+	seconds := r.Duration.Seconds()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.inFlight--
+	e.pushes[key]++
+	e.bytesSent += r.BytesSent
+	e.sum += seconds
+	e.total++
+	for i, bound := range e.Buckets {
+		if seconds <= bound {
+			e.counts[i]++
+		}
+	}
+}
+
+// TokenGenerated increments the number of provider tokens generated.
+func (e *Exporter) TokenGenerated() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tokens++
+}
+
+// ClientEvicted increments the number of clients evicted from managers.
+func (e *Exporter) ClientEvicted() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.evictions++
+}
+
+// ServeHTTP writes the metrics in the text exposition format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = e.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the text exposition format.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	e.init()
+	namespace := e.Namespace
+	if namespace == "" {
+		namespace = "apns2"
+	}
+	var buf bytes.Buffer
+
+	e.mu.Lock()
+	keys := make([]pushKey, 0, len(e.pushes))
+	for key := range e.pushes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.status != b.status {
+			return a.status < b.status
+		}
+		if a.reason != b.reason {
+			return a.reason < b.reason
+		}
+		if a.topic != b.topic {
+			return a.topic < b.topic
+		}
+		return a.pushType < b.pushType
+	})
+
+	name := namespace + "_pushes_total"
+	header(&buf, name, "counter", "Requests sent to APNs by status, reason, topic and push type.")
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%v{status=%v,reason=%v,topic=%v,push_type=%v} %v\n", name,
+			quote(key.status), quote(key.reason), quote(key.topic), quote(key.pushType), e.pushes[key])
+	}
+
+	name = namespace + "_request_duration_seconds"
+	header(&buf, name, "histogram", "Duration of the requests sent to APNs.")
+	for i, bound := range e.Buckets {
+		fmt.Fprintf(&buf, "%v_bucket{le=%v} %v\n", name, quote(formatFloat(bound)), e.counts[i])
+	}
+	fmt.Fprintf(&buf, "%v_bucket{le=\"+Inf\"} %v\n", name, e.total)
+	fmt.Fprintf(&buf, "%v_sum %v\n", name, formatFloat(e.sum))
+	fmt.Fprintf(&buf, "%v_count %v\n", name, e.total)
+
+	name = namespace + "_requests_in_flight"
+	header(&buf, name, "gauge", "Requests sent to APNs awaiting a response.")
+	fmt.Fprintf(&buf, "%v %v\n", name, e.inFlight)
+
+	name = namespace + "_sent_bytes_total"
+	header(&buf, name, "counter", "Payload bytes sent to APNs.")
+	fmt.Fprintf(&buf, "%v %v\n", name, e.bytesSent)
+
+	name = namespace + "_token_generations_total"
+	header(&buf, name, "counter", "Provider tokens generated.")
+	fmt.Fprintf(&buf, "%v %v\n", name, e.tokens)
+
+	name = namespace + "_client_evictions_total"
+	header(&buf, name, "counter", "Clients evicted from client managers.")
+	fmt.Fprintf(&buf, "%v %v\n", name, e.evictions)
+	e.mu.Unlock()
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+func (e *Exporter) init() {
+	e.initBuckets.Do(func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.Buckets == nil {
+			e.Buckets = DefaultBuckets
+		}
+		e.counts = make([]uint64, len(e.Buckets))
+		e.pushes = map[pushKey]uint64{}
+	})
+}
+
+func header(buf *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(buf, "# HELP %v %v\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %v %v\n", name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quote(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// errorKind returns the label of a request which received no response.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, apns.ErrDial):
+		return "dial"
+	case errors.Is(err, apns.ErrTLSHandshake):
+		return "tls_handshake"
+	case errors.Is(err, apns.ErrStreamReset):
+		return "stream_reset"
+	case errors.Is(err, apns.ErrGoAway):
+		return "goaway"
+	case errors.Is(err, apns.ErrTimeout):
+		return "timeout"
+	case errors.Is(err, apns.ErrConnLost):
+		return "conn_lost"
+	}
+	return "other"
+}
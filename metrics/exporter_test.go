@@ -0,0 +1,103 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/metrics"
+)
+
+func TestExporter(t *testing.T) {
+	exporter := metrics.NewExporter()
+	exporter.Buckets = []float64{0.1, 1}
+	for i := 0; i < 2; i++ {
+		exporter.RequestStarted()
+		exporter.RequestFinished(apns.RequestMetrics{
+			Topic:      "com.testapp",
+			PushType:   apns.PushTypeAlert,
+			StatusCode: 200,
+			Duration:   50 * time.Millisecond,
+			BytesSent:  100,
+		})
+	}
+	exporter.RequestStarted()
+	exporter.RequestFinished(apns.RequestMetrics{
+		Topic:      "com.\"test\"app",
+		PushType:   apns.PushTypeVOIP,
+		StatusCode: 410,
+		Reason:     apns.ReasonUnregistered,
+		Duration:   500 * time.Millisecond,
+		BytesSent:  10,
+	})
+	exporter.RequestStarted()
+	exporter.RequestFinished(apns.RequestMetrics{
+		PushType: apns.PushTypeAlert,
+		Err:      &apns.TransportError{Kind: apns.ErrGoAway, Err: errors.New("goaway")},
+		Duration: 2 * time.Second,
+	})
+	exporter.RequestStarted()
+	exporter.TokenGenerated()
+	exporter.ClientEvicted()
+
+	var buf bytes.Buffer
+	if _, err := exporter.WriteTo(&buf); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	expected := `# HELP apns2_pushes_total Requests sent to APNs by status, reason, topic and push type.
+# TYPE apns2_pushes_total counter
+apns2_pushes_total{status="200",reason="",topic="com.testapp",push_type="alert"} 2
+apns2_pushes_total{status="410",reason="Unregistered",topic="com.\"test\"app",push_type="voip"} 1
+apns2_pushes_total{status="error",reason="goaway",topic="",push_type="alert"} 1
+# HELP apns2_request_duration_seconds Duration of the requests sent to APNs.
+# TYPE apns2_request_duration_seconds histogram
+apns2_request_duration_seconds_bucket{le="0.1"} 2
+apns2_request_duration_seconds_bucket{le="1"} 3
+apns2_request_duration_seconds_bucket{le="+Inf"} 4
+apns2_request_duration_seconds_sum 2.6
+apns2_request_duration_seconds_count 4
+# HELP apns2_requests_in_flight Requests sent to APNs awaiting a response.
+# TYPE apns2_requests_in_flight gauge
+apns2_requests_in_flight 1
+# HELP apns2_sent_bytes_total Payload bytes sent to APNs.
+# TYPE apns2_sent_bytes_total counter
+apns2_sent_bytes_total 210
+# HELP apns2_token_generations_total Provider tokens generated.
+# TYPE apns2_token_generations_total counter
+apns2_token_generations_total 1
+# HELP apns2_client_evictions_total Clients evicted from client managers.
+# TYPE apns2_client_evictions_total counter
+apns2_client_evictions_total 1
+`
+	if expected != buf.String() {
+		t.Fatal("Expected:", expected, " found:", buf.String())
+	}
+}
+
+func TestExporterHandler(t *testing.T) {
+	exporter := metrics.NewExporter()
+	exporter.Namespace = "push"
+	server := httptest.NewServer(exporter)
+	defer server.Close()
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	defer res.Body.Close()
+	if metrics.ContentType != res.Header.Get("Content-Type") {
+		t.Fatal("Expected:", metrics.ContentType, " found:", res.Header.Get("Content-Type"))
+	}
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(res.Body)
+	if !strings.Contains(buf.String(), "push_requests_in_flight 0\n") {
+		t.Fatal("Unexpected body:", buf.String())
+	}
+}
@@ -0,0 +1,117 @@
+package apns2_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+type mockMetrics struct {
+	mu        sync.Mutex
+	started   int
+	finished  []apns.RequestMetrics
+	tokens    int
+	evictions int
+}
+
+func (m *mockMetrics) RequestStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started++
+}
+
+func (m *mockMetrics) RequestFinished(r apns.RequestMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finished = append(m.finished, r)
+}
+
+func (m *mockMetrics) TokenGenerated() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens++
+}
+
+func (m *mockMetrics) ClientEvicted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.evictions++
+}
+
+func TestClientMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+	}))
+	defer server.Close()
+
+	metrics := &mockMetrics{}
+	client := mockClient(server.URL)
+	client.Token = mockToken()
+	client.Metrics = metrics
+	n := mockNotification()
+	n.Topic = "com.testapp"
+	for i := 0; i < 2; i++ {
+		if _, err := client.Push(n); err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+	}
+
+	if 2 != metrics.started || 2 != len(metrics.finished) {
+		t.Fatal("Expected:", 2, " found:", metrics.started, len(metrics.finished))
+	}
+	if 1 != metrics.tokens {
+		t.Fatal("Expected:", 1, " found:", metrics.tokens)
+	}
+	r := metrics.finished[0]
+	if http.StatusBadRequest != r.StatusCode || apns.ReasonBadDeviceToken != r.Reason || r.Err != nil {
+		t.Fatal("Unexpected request metrics:", r)
+	}
+	if "com.testapp" != r.Topic || apns.PushTypeAlert != r.PushType {
+		t.Fatal("Unexpected request metrics:", r)
+	}
+	if int64(len(n.Payload.([]byte))) != r.BytesSent || r.Duration <= 0 {
+		t.Fatal("Unexpected request metrics:", r)
+	}
+}
+
+func TestClientMetricsTransportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	metrics := &mockMetrics{}
+	client := mockClient(server.URL)
+	client.Metrics = metrics
+	if _, err := client.Push(mockNotification()); err == nil {
+		t.Fatal("Expected error, found nil")
+	}
+	if 1 != len(metrics.finished) || 0 != metrics.finished[0].StatusCode || metrics.finished[0].Err == nil {
+		t.Fatal("Unexpected request metrics:", metrics.finished)
+	}
+}
+
+func TestClientMetricsTokenRenewError(t *testing.T) {
+	client := mockClient("https://localhost:1")
+	client.Token = mockToken()
+	client.Token.AuthKey = nil
+	if _, err := client.Push(mockNotification()); err == nil {
+		t.Fatal("Expected error, found nil")
+	}
+}
+
+func TestClientManagerMetrics(t *testing.T) {
+	metrics := &mockMetrics{}
+	manager := apns.NewClientManager()
+	manager.Metrics = metrics
+	manager.MaxAge = time.Nanosecond
+	manager.Get(mockCert())
+	time.Sleep(time.Microsecond)
+	manager.Get(mockCert())
+	if 1 != metrics.evictions {
+		t.Fatal("Expected:", 1, " found:", metrics.evictions)
+	}
+}
@@ -0,0 +1,178 @@
+package apns2
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Multicast represents the data and metadata of a notification sent
+// identically to many devices. See Notification for the meaning of each
+// field.
+type Multicast struct {
+	CollapseID string
+	Topic      string
+	Expiration time.Time
+	Priority   int
+	PushType   EPushType
+	Payload    interface{}
+}
+
+// TokenIterator yields the device tokens a Multicast is sent to. Next returns
+// false once there are no more tokens.
+type TokenIterator interface {
+	Next() (token string, ok bool)
+}
+
+type tokenSlice struct {
+	tokens []string
+	next   int
+}
+
+// TokenSlice returns a TokenIterator over the given device tokens.
+func TokenSlice(tokens []string) TokenIterator {
+	return &tokenSlice{tokens: tokens}
+}
+
+func (s *tokenSlice) Next() (string, bool) {
+	if s.next >= len(s.tokens) {
+		return "", false
+	}
+	s.next++
+	return s.tokens[s.next-1], true
+}
+
+// MulticastResult is the outcome of a Multicast for a single device token.
+type MulticastResult struct {
+	DeviceToken string
+	Response    *Response
+	Err         error
+}
+
+// MulticastSummary aggregates the outcome of a Multicast.
+type MulticastSummary struct {
+	// The number of distinct device tokens the notification was pushed to.
+	Total int
+
+	// The number of device tokens skipped because they were already seen.
+	Duplicates int
+
+	// The number of notifications accepted by APNs.
+	Sent int
+
+	// The number of notifications rejected by APNs.
+	Rejected int
+
+	// The number of pushes which failed without a response from APNs.
+	Errors int
+
+	// The number of rejected notifications for each APNs Reason.
+	Reasons map[string]int
+}
+
+// MulticastResponse holds the per-token results of a Multicast, in the order
+// the device tokens were given, and their summary.
+type MulticastResponse struct {
+	Results []MulticastResult
+	Summary MulticastSummary
+}
+
+// PushMulticast sends the Multicast to every device token in tokens. See
+// PushMulticastIter.
+func (c *Client) PushMulticast(ctx Context, m *Multicast, tokens []string, opts *PushMultiOptions) (*MulticastResponse, error) {
+	return c.PushMulticastIter(ctx, m, TokenSlice(tokens), opts)
+}
+
+// PushMulticastIter sends the Multicast to every device token yielded by
+// tokens, running up to opts.Concurrency pushes at the same time. The payload
+// is marshalled once and the same body is reused for every device token.
+// Duplicated device tokens are pushed only once.
+//
+// An error is returned only if the payload cannot be marshalled. When the
+// context is done, no further device tokens are read from the iterator.
+// opts can be nil.
+func (c *Client) PushMulticastIter(ctx Context, m *Multicast, tokens TokenIterator, opts *PushMultiOptions) (*MulticastResponse, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	payload, err := json.Marshal(&Notification{Payload: m.Payload})
+	if err != nil {
+		return nil, err
+	}
+
+	type job struct {
+		index int
+		token string
+	}
+	jobs := make(chan job)
+	response := &MulticastResponse{
+		Summary: MulticastSummary{Reasons: map[string]int{}},
+	}
+	mu := sync.Mutex{}
+
+	workers := opts.concurrency()
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				result := MulticastResult{DeviceToken: j.token}
+				if result.Err = ctx.Err(); result.Err == nil {
+					result.Response, result.Err = c.push(ctx, m.notification(j.token, payload), payload)
+				}
+				mu.Lock()
+				response.Results[j.index] = result
+				response.Summary.add(result)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	seen := map[string]struct{}{}
+	for ctx.Err() == nil {
+		token, ok := tokens.Next()
+		if !ok {
+			break
+		}
+		if _, duplicate := seen[token]; duplicate {
+			response.Summary.Duplicates++
+			continue
+		}
+		seen[token] = struct{}{}
+		mu.Lock()
+		response.Results = append(response.Results, MulticastResult{})
+		index := len(response.Results) - 1
+		mu.Unlock()
+		jobs <- job{index, token}
+	}
+	close(jobs)
+	wg.Wait()
+	response.Summary.Total = len(response.Results)
+	return response, nil
+}
+
+func (m *Multicast) notification(token string, payload []byte) *Notification {
+	return &Notification{
+		CollapseID:  m.CollapseID,
+		DeviceToken: token,
+		Topic:       m.Topic,
+		Expiration:  m.Expiration,
+		Priority:    m.Priority,
+		Payload:     payload,
+		PushType:    m.PushType,
+	}
+}
+
+func (s *MulticastSummary) add(r MulticastResult) {
+	switch {
+	case r.Err != nil:
+		s.Errors++
+	case r.Response.Sent():
+		s.Sent++
+	default:
+		s.Rejected++
+		s.Reasons[r.Response.Reason]++
+	}
+}
@@ -0,0 +1,140 @@
+package apns2_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/payload"
+)
+
+func mockTokens(count int) []string {
+	tokens := make([]string, count)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("%064x", i)
+	}
+	return tokens
+}
+
+func TestTokenSlice(t *testing.T) {
+	it := apns.TokenSlice([]string{"a", "b"})
+	for _, expected := range []string{"a", "b"} {
+		token, ok := it.Next()
+		if !ok || expected != token {
+			t.Fatal("Expected:", expected, " found:", token)
+		}
+	}
+	if _, ok := it.Next(); ok {
+		t.Fatal("Expected iterator to be exhausted")
+	}
+}
+
+func TestPushMulticast(t *testing.T) {
+	var requests int32
+	expiration := time.Now().Add(time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		if `{"aps":{"alert":"Hello!"}}` != string(body) {
+			t.Error("Expected:", `{"aps":{"alert":"Hello!"}}`, " found:", string(body))
+		}
+		if "com.testapp" != r.Header.Get("apns-topic") {
+			t.Error("Expected:", "com.testapp", " found:", r.Header.Get("apns-topic"))
+		}
+		if "background" != r.Header.Get("apns-push-type") {
+			t.Error("Expected:", "background", " found:", r.Header.Get("apns-push-type"))
+		}
+		if "5" != r.Header.Get("apns-priority") {
+			t.Error("Expected:", "5", " found:", r.Header.Get("apns-priority"))
+		}
+		if "game1" != r.Header.Get("apns-collapse-id") {
+			t.Error("Expected:", "game1", " found:", r.Header.Get("apns-collapse-id"))
+		}
+		if fmt.Sprintf("%v", expiration.Unix()) != r.Header.Get("apns-expiration") {
+			t.Error("Expected:", expiration.Unix(), " found:", r.Header.Get("apns-expiration"))
+		}
+		token := strings.TrimPrefix(r.URL.Path, "/3/device/")
+		switch {
+		case strings.HasSuffix(token, "1"):
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte(`{"reason":"Unregistered"}`))
+		case strings.HasSuffix(token, "2"):
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+		}
+	}))
+	defer server.Close()
+
+	m := &apns.Multicast{
+		CollapseID: "game1",
+		Topic:      "com.testapp",
+		Expiration: expiration,
+		Priority:   apns.PriorityLow,
+		PushType:   apns.PushTypeBackground,
+		Payload:    payload.NewPayload().Alert("Hello!"),
+	}
+	tokens := append(mockTokens(16), mockTokens(4)...)
+	res, err := mockClient(server.URL).PushMulticast(context.Background(), m, tokens, &apns.PushMultiOptions{Concurrency: 4})
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if 16 != atomic.LoadInt32(&requests) {
+		t.Fatal("Expected:", 16, " found:", requests)
+	}
+	if 16 != len(res.Results) {
+		t.Fatal("Expected:", 16, " found:", len(res.Results))
+	}
+	for i, result := range res.Results {
+		if tokens[i] != result.DeviceToken {
+			t.Fatal("Expected:", tokens[i], " found:", result.DeviceToken)
+		}
+	}
+	summary := res.Summary
+	if 16 != summary.Total || 4 != summary.Duplicates || 14 != summary.Sent || 2 != summary.Rejected || 0 != summary.Errors {
+		t.Fatal("Unexpected summary:", summary)
+	}
+	if 1 != summary.Reasons[apns.ReasonUnregistered] || 1 != summary.Reasons[apns.ReasonBadDeviceToken] {
+		t.Fatal("Unexpected reasons:", summary.Reasons)
+	}
+}
+
+func TestPushMulticastIterCancelled(t *testing.T) {
+	var requests int32
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 3 {
+			cancel()
+		}
+	}))
+	defer server.Close()
+
+	m := &apns.Multicast{Payload: []byte(`{"aps":{"alert":"Hello!"}}`)}
+	res, err := mockClient(server.URL).PushMulticastIter(ctx, m, apns.TokenSlice(mockTokens(100)), &apns.PushMultiOptions{Concurrency: 1})
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if res.Summary.Total >= 100 {
+		t.Fatal("Expected tokens to stop being read after cancellation, found:", res.Summary.Total)
+	}
+	if res.Summary.Total != len(res.Results) {
+		t.Fatal("Expected:", len(res.Results), " found:", res.Summary.Total)
+	}
+}
+
+func TestPushMulticastBadPayload(t *testing.T) {
+	m := &apns.Multicast{Payload: func() {}}
+	res, err := mockClient("").PushMulticast(context.Background(), m, mockTokens(1), nil)
+	if err == nil {
+		t.Fatal("Expected error, found nil")
+	}
+	if res != nil {
+		t.Fatal("res expected nil, found:", res)
+	}
+}
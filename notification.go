@@ -1,7 +1,11 @@
 package apns2
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -135,3 +139,22 @@ func (n *Notification) MarshalJSON() ([]byte, error) {
 		return json.Marshal(n.Payload)
 	}
 }
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// NewApnsID returns a random canonical UUID, suitable as the ApnsID of a
+// Notification.
+func NewApnsID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	id := strings.ToUpper(hex.EncodeToString(b))
+	return id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:]
+}
+
+// ValidApnsID reports whether id is a canonical UUID, as APNs requires for
+// the ApnsID of a Notification.
+func ValidApnsID(id string) bool {
+	return uuidPattern.MatchString(id)
+}
@@ -35,3 +35,16 @@ func TestMarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestNewApnsID(t *testing.T) {
+	id := apns2.NewApnsID()
+	if !apns2.ValidApnsID(id) {
+		t.Fatal("Expected a canonical UUID, found:", id)
+	}
+	if id == apns2.NewApnsID() {
+		t.Fatal("Expected random identifiers, found:", id, "twice")
+	}
+	if apns2.ValidApnsID("not-a-uuid") {
+		t.Fatal("Expected:", false, " found:", true)
+	}
+}
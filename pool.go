@@ -0,0 +1,317 @@
+package apns2
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/http2"
+)
+
+// PoolStrategy selects which connection of a ConnPool carries a request.
+type PoolStrategy int
+
+const (
+	// PoolLeastInFlight sends each request over the connection with the
+	// fewest in-flight streams. A new connection is opened, up to the pool
+	// size, only when all the existing ones are busy.
+	PoolLeastInFlight PoolStrategy = iota
+
+	// PoolRoundRobin cycles through the connections of the pool, opening all
+	// of them up to the pool size.
+	PoolRoundRobin
+)
+
+// ConnStats describes the state of a connection maintained by a ConnPool.
+type ConnStats struct {
+	// The host:port address the connection is established with.
+	Addr string
+
+	// The number of requests sent over the connection whose response body
+	// has not been closed yet.
+	InFlight int
+
+	// The number of HTTP/2 streams currently open on the connection.
+	Streams int
+
+	// The SETTINGS_MAX_CONCURRENT_STREAMS value advertised by APNs, or zero
+	// if it was not received yet.
+	MaxConcurrentStreams uint32
+}
+
+// ConnPool is an http.RoundTripper which maintains multiple HTTP/2
+// connections per host and spreads requests over them, lifting the limit on
+// concurrent streams that APNs sets on a single connection. Connections which
+// received a GOAWAY frame or were closed are replaced on the next request.
+type ConnPool struct {
+	// Size is the maximum number of connections maintained per host. Values
+	// lower than 1 are treated as 1.
+	Size int
+
+	// Strategy selects the connection for each request.
+	Strategy PoolStrategy
+
+	// Transport is used to dial and configure the pooled connections.
+	Transport *http2.Transport
+
+	mu    sync.Mutex
+	hosts map[string]*poolHost
+}
+
+type poolHost struct {
+	conns   []*poolConn
+	dialing int
+	dialed  chan struct{} // closed when a dial completes
+	next    int
+}
+
+type poolConn struct {
+	addr     string
+	conn     net.Conn
+	cc       *http2.ClientConn
+	inFlight int32
+}
+
+type poolBody struct {
+	io.ReadCloser
+	conn *poolConn
+	once sync.Once
+}
+
+// NewConnPool returns a new ConnPool maintaining up to size connections per
+// host, created with the given transport.
+func NewConnPool(transport *http2.Transport, size int, strategy PoolStrategy) *ConnPool {
+	return &ConnPool{
+		Size:      size,
+		Strategy:  strategy,
+		Transport: transport,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface. The GetConn and
+// GotConn hooks of the httptrace.ClientTrace of the request, if any, are
+// called around the selection of the connection.
+func (p *ConnPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("apns2: unsupported scheme %q", req.URL.Scheme)
+	}
+	addr := authorityAddr(req.URL.Host)
+	trace := httptrace.ContextClientTrace(req.Context())
+	if trace != nil && trace.GetConn != nil {
+		trace.GetConn(addr)
+	}
+	pc, info, err := p.conn(req.Context(), addr)
+	if err != nil {
+		return nil, err
+	}
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(info)
+	}
+	res, err := pc.cc.RoundTrip(req)
+	if err != nil {
+		atomic.AddInt32(&pc.inFlight, -1)
+		return nil, err
+	}
+	res.Body = &poolBody{ReadCloser: res.Body, conn: pc}
+	return res, nil
+}
+
+// CloseIdleConnections closes the pooled connections which have no in-flight
+// requests.
+func (p *ConnPool) CloseIdleConnections() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, h := range p.hosts {
+		conns := h.conns[:0]
+		for _, pc := range h.conns {
+			if atomic.LoadInt32(&pc.inFlight) == 0 {
+				_ = pc.cc.Close()
+				continue
+			}
+			conns = append(conns, pc)
+		}
+		h.conns = conns
+	}
+}
+
+// Stats returns the state of every connection currently in the pool, sorted
+// by address.
+func (p *ConnPool) Stats() []ConnStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var stats []ConnStats
+	for _, h := range p.hosts {
+		for _, pc := range h.conns {
+			state := pc.cc.State()
+			stats = append(stats, ConnStats{
+				Addr:                 pc.addr,
+				InFlight:             int(atomic.LoadInt32(&pc.inFlight)),
+				Streams:              state.StreamsActive,
+				MaxConcurrentStreams: state.MaxConcurrentStreams,
+			})
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Addr < stats[j].Addr
+	})
+	return stats
+}
+
+// conn selects the connection for a request to addr, dialing a new one when
+// needed, and accounts the request as in-flight on it. When no connection is
+// established and the pool is full of connections being dialed, it waits for
+// one of the dials to complete or for ctx to be done.
+func (p *ConnPool) conn(ctx context.Context, addr string) (*poolConn, httptrace.GotConnInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.hosts == nil {
+		p.hosts = map[string]*poolHost{}
+	}
+	h, ok := p.hosts[addr]
+	if !ok {
+		h = &poolHost{}
+		p.hosts[addr] = h
+	}
+	size := p.Size
+	if size < 1 {
+		size = 1
+	}
+
+	for {
+		h.prune()
+		selected := h.pick(p.Strategy)
+		if len(h.conns)+h.dialing < size && (selected == nil || p.Strategy == PoolRoundRobin || atomic.LoadInt32(&selected.inFlight) > 0) {
+			h.dialing++
+			p.mu.Unlock()
+			pc, err := p.dial(addr)
+			p.mu.Lock()
+			h.dialing--
+			if h.dialed != nil {
+				close(h.dialed)
+				h.dialed = nil
+			}
+			if err == nil {
+				h.conns = append(h.conns, pc)
+				atomic.AddInt32(&pc.inFlight, 1)
+				return pc, httptrace.GotConnInfo{Conn: pc.conn}, nil
+			}
+			if selected == nil || !selected.cc.CanTakeNewRequest() {
+				return nil, httptrace.GotConnInfo{}, err
+			}
+		}
+		if selected != nil {
+			idle := atomic.AddInt32(&selected.inFlight, 1) == 1
+			return selected, httptrace.GotConnInfo{Conn: selected.conn, Reused: true, WasIdle: idle}, nil
+		}
+		if h.dialing == 0 {
+			return nil, httptrace.GotConnInfo{}, errors.New("apns2: no connection available")
+		}
+
+		if h.dialed == nil {
+			h.dialed = make(chan struct{})
+		}
+		dialed := h.dialed
+		p.mu.Unlock()
+		select {
+		case <-dialed:
+			p.mu.Lock()
+		case <-ctx.Done():
+			p.mu.Lock()
+			return nil, httptrace.GotConnInfo{}, ctx.Err()
+		}
+	}
+}
+
+func (p *ConnPool) dial(addr string) (*poolConn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(tls.Config)
+	if p.Transport.TLSClientConfig != nil {
+		cfg = p.Transport.TLSClientConfig.Clone()
+	}
+	cfg.NextProtos = []string{http2.NextProtoTLS}
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+	var conn net.Conn
+	if p.Transport.DialTLS != nil {
+		conn, err = p.Transport.DialTLS("tcp", addr, cfg)
+	} else {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: TLSDialTimeout}, "tcp", addr, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cc, err := p.Transport.NewClientConn(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &poolConn{addr: addr, conn: conn, cc: cc}, nil
+}
+
+// prune removes the connections which are closed or received a GOAWAY frame.
+// Their in-flight requests are left to complete, after which the connection is
+// closed.
+func (h *poolHost) prune() {
+	conns := h.conns[:0]
+	for _, pc := range h.conns {
+		state := pc.cc.State()
+		if !state.Closed && !state.Closing {
+			conns = append(conns, pc)
+			continue
+		}
+		pc.cc.SetDoNotReuse()
+		if state = pc.cc.State(); !state.Closed && state.StreamsActive == 0 {
+			_ = pc.cc.Close()
+		}
+	}
+	for i := len(conns); i < len(h.conns); i++ {
+		h.conns[i] = nil
+	}
+	h.conns = conns
+}
+
+func (h *poolHost) pick(strategy PoolStrategy) *poolConn {
+	if len(h.conns) == 0 {
+		return nil
+	}
+	if strategy == PoolRoundRobin {
+		h.next = (h.next + 1) % len(h.conns)
+		return h.conns[h.next]
+	}
+	selected := h.conns[0]
+	for _, pc := range h.conns[1:] {
+		if atomic.LoadInt32(&pc.inFlight) < atomic.LoadInt32(&selected.inFlight) {
+			selected = pc
+		}
+	}
+	return selected
+}
+
+func (b *poolBody) Close() error {
+	b.once.Do(func() {
+		atomic.AddInt32(&b.conn.inFlight, -1)
+	})
+	return b.ReadCloser.Close()
+}
+
+// authorityAddr returns the host:port address for the given URL host,
+// defaulting to the HTTPS port.
+func authorityAddr(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), "443")
+}
@@ -0,0 +1,190 @@
+package apns2_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/http2"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+// Mocks
+
+func mockHTTP2Server(handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	return server
+}
+
+func mockHTTP2Client(server *httptest.Server) *apns.Client {
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig
+	transport := &http2.Transport{TLSClientConfig: tlsConfig}
+	return &apns.Client{Host: server.URL, HTTPClient: &http.Client{Transport: transport}}
+}
+
+// Unit Tests
+
+func TestWithConnPool(t *testing.T) {
+	client := apns.NewClient(mockCert()).WithConnPool(4, apns.PoolRoundRobin)
+	pool, ok := client.HTTPClient.Transport.(*apns.ConnPool)
+	if !ok {
+		t.Fatal("Expected transport to be a ConnPool")
+	}
+	if 4 != pool.Size || apns.PoolRoundRobin != pool.Strategy {
+		t.Fatal("Expected:", 4, apns.PoolRoundRobin, " found:", pool.Size, pool.Strategy)
+	}
+	client.WithConnPool(2, apns.PoolLeastInFlight)
+	if 2 != pool.Size || apns.PoolLeastInFlight != pool.Strategy {
+		t.Fatal("Expected:", 2, apns.PoolLeastInFlight, " found:", pool.Size, pool.Strategy)
+	}
+	if 0 != len(client.ConnStats()) {
+		t.Fatal("Expected:", 0, " found:", len(client.ConnStats()))
+	}
+}
+
+func TestConnStatsWithoutPool(t *testing.T) {
+	if nil != apns.NewClient(mockCert()).ConnStats() {
+		t.Fatal("Expected nil stats")
+	}
+}
+
+// Functional Tests
+
+func TestConnPoolRoundRobin(t *testing.T) {
+	var mu sync.Mutex
+	remotes := map[string]int{}
+	server := mockHTTP2Server(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remotes[r.RemoteAddr]++
+		mu.Unlock()
+	})
+	defer server.Close()
+
+	client := mockHTTP2Client(server).WithConnPool(3, apns.PoolRoundRobin)
+	for i := 0; i < 9; i++ {
+		res, err := client.Push(mockNotification())
+		if err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+		if !res.Sent() {
+			t.Fatal("Expected:", true, " found:", res.Sent())
+		}
+	}
+	if 3 != len(remotes) {
+		t.Fatal("Expected:", 3, " found:", len(remotes))
+	}
+	for remote, count := range remotes {
+		if 3 != count {
+			t.Fatal("Expected:", 3, " found:", count, "for", remote)
+		}
+	}
+	if 3 != len(client.ConnStats()) {
+		t.Fatal("Expected:", 3, " found:", len(client.ConnStats()))
+	}
+}
+
+func TestConnPoolLeastInFlight(t *testing.T) {
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	server := mockHTTP2Server(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	})
+	defer server.Close()
+
+	client := mockHTTP2Client(server).WithConnPool(2, apns.PoolLeastInFlight)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Push(mockNotification()); err != nil {
+				t.Error("Expected no error, found:", err)
+			}
+		}()
+		<-arrived
+	}
+
+	stats := client.ConnStats()
+	if 2 != len(stats) {
+		t.Fatal("Expected:", 2, " found:", len(stats))
+	}
+	for _, s := range stats {
+		if 2 != s.InFlight || 2 != s.Streams {
+			t.Fatal("Expected:", 2, " found:", s.InFlight, s.Streams)
+		}
+	}
+	close(release)
+	wg.Wait()
+	for _, s := range client.ConnStats() {
+		if 0 != s.InFlight {
+			t.Fatal("Expected:", 0, " found:", s.InFlight)
+		}
+	}
+}
+
+func TestConnPoolReplacesClosedConnections(t *testing.T) {
+	server := mockHTTP2Server(func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Close()
+
+	client := mockHTTP2Client(server).WithConnPool(1, apns.PoolRoundRobin)
+	if _, err := client.Push(mockNotification()); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	server.CloseClientConnections()
+	var err error
+	for i := 0; i < 3; i++ {
+		if _, err = client.Push(mockNotification()); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if 1 != len(client.ConnStats()) {
+		t.Fatal("Expected:", 1, " found:", len(client.ConnStats()))
+	}
+}
+
+func TestConnPoolCloseIdleConnections(t *testing.T) {
+	server := mockHTTP2Server(func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Close()
+
+	client := mockHTTP2Client(server).WithConnPool(2, apns.PoolRoundRobin)
+	for i := 0; i < 2; i++ {
+		if _, err := client.Push(mockNotification()); err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+	}
+	client.CloseIdleConnections()
+	if 0 != len(client.ConnStats()) {
+		t.Fatal("Expected:", 0, " found:", len(client.ConnStats()))
+	}
+}
+
+func TestConnPoolConcurrentFirstPushes(t *testing.T) {
+	server := mockHTTP2Server(func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Close()
+
+	for _, strategy := range []apns.PoolStrategy{apns.PoolLeastInFlight, apns.PoolRoundRobin} {
+		client := mockHTTP2Client(server).WithConnPool(2, strategy)
+		wg := sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+					t.Error("Expected notification to be sent, found:", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if stats := client.ConnStats(); 0 == len(stats) || len(stats) > 2 {
+			t.Fatal("Expected:", 2, " found:", len(stats))
+		}
+	}
+}
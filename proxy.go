@@ -0,0 +1,142 @@
+package apns2
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// proxyDialer returns a function dialing connections through the proxy, with
+// the dialer connecting to the proxy. The CONNECT or SOCKS5 handshake must
+// complete within timeout.
+func proxyDialer(proxyURL *url.URL, dialer *net.Dialer, timeout time.Duration) func(network, addr string) (net.Conn, error) {
+	if proxyURL.Scheme == "socks5" || proxyURL.Scheme == "socks5h" {
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+		return func(network, addr string) (net.Conn, error) {
+			ctx := context.Background()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			// Only socks5h leaves the resolution of the host to the proxy.
+			if proxyURL.Scheme == "socks5" {
+				var err error
+				if addr, err = resolve(ctx, dialer, addr); err != nil {
+					return nil, err
+				}
+			}
+			socks, err := proxy.SOCKS5("tcp", proxyAddr(proxyURL), auth, dialer)
+			if err != nil {
+				return nil, err
+			}
+			contextDialer, ok := socks.(proxy.ContextDialer)
+			if !ok {
+				return socks.Dial(network, addr)
+			}
+			return contextDialer.DialContext(ctx, network, addr)
+		}
+	}
+	return func(network, addr string) (net.Conn, error) {
+		return dialConnect(proxyURL, dialer, timeout, addr)
+	}
+}
+
+// resolve replaces the host of addr with its first IP address, looked up with
+// the resolver of the dialer.
+func resolve(ctx context.Context, dialer *net.Dialer, addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(host) != nil {
+		return addr, nil
+	}
+	resolver := dialer.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ips, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("apns2: no address found for %v", host)
+	}
+	return net.JoinHostPort(ips[0].IP.String(), port), nil
+}
+
+// dialConnect opens a tunnel to addr through the HTTP proxy with a CONNECT
+// request.
+func dialConnect(proxyURL *url.URL, dialer *net.Dialer, timeout time.Duration, addr string) (net.Conn, error) {
+	start := time.Now()
+	conn, err := dialer.Dial("tcp", proxyAddr(proxyURL))
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "https" {
+		host, _, _ := net.SplitHostPort(proxyAddr(proxyURL))
+		conn = tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	}
+	if timeout > 0 {
+		_ = conn.SetDeadline(start.Add(timeout))
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := proxyURL.User.Username() + ":" + password
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	// The proxy sends nothing after its response until the TLS handshake
+	// starts, so nothing is lost by discarding the reader.
+	res, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("apns2: proxy CONNECT to %v failed: %v", addr, res.Status)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// proxyAddr returns the host:port address of the proxy, adding the default
+// port of its scheme if missing.
+func proxyAddr(proxyURL *url.URL) string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+	port := "80"
+	switch proxyURL.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
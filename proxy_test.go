@@ -0,0 +1,334 @@
+package apns2_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/apns2test"
+)
+
+// proxyStandIn is a local HTTP CONNECT or SOCKS5 proxy, requiring the
+// credentials of its URL.
+type proxyStandIn struct {
+	URL         *url.URL
+	Established string
+	listener    net.Listener
+	tunnels     int32
+	addressType int32
+}
+
+func newProxyStandIn(t *testing.T, scheme string) *proxyStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &proxyStandIn{
+		URL:         &url.URL{Scheme: scheme, User: url.UserPassword("user", "secret"), Host: listener.Addr().String()},
+		Established: "HTTP/1.1 200 Connection established\r\n\r\n",
+		listener:    listener,
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(scheme, "socks5") {
+				go p.serveSOCKS5(conn)
+			} else {
+				go p.serveConnect(conn)
+			}
+		}
+	}()
+	return p
+}
+
+func (p *proxyStandIn) Close() {
+	p.listener.Close()
+}
+
+func (p *proxyStandIn) serveConnect(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil || http.MethodConnect != req.Method {
+		return
+	}
+	user, password, ok := (&http.Request{Header: http.Header{"Authorization": req.Header["Proxy-Authorization"]}}).BasicAuth()
+	if !ok || "user" != user || "secret" != password {
+		_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+		return
+	}
+	target, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer target.Close()
+	_, _ = io.WriteString(conn, p.Established)
+	atomic.AddInt32(&p.tunnels, 1)
+	go func() {
+		_, _ = io.Copy(target, reader)
+		target.Close()
+	}()
+	_, _ = io.Copy(conn, target)
+}
+
+func (p *proxyStandIn) serveSOCKS5(conn net.Conn) {
+	defer conn.Close()
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil || 5 != header[0] {
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil || !strings.Contains(string(methods), "\x02") {
+		_, _ = conn.Write([]byte{5, 0xff})
+		return
+	}
+	_, _ = conn.Write([]byte{5, 2})
+
+	// Username and password authentication, RFC 1929.
+	readString := func() string {
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return ""
+		}
+		s := make([]byte, length[0])
+		_, _ = io.ReadFull(conn, s)
+		return string(s)
+	}
+	if _, err := io.ReadFull(conn, header[:1]); err != nil {
+		return
+	}
+	if user, password := readString(), readString(); "user" != user || "secret" != password {
+		_, _ = conn.Write([]byte{1, 1})
+		return
+	}
+	_, _ = conn.Write([]byte{1, 0})
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil || 1 != request[1] {
+		return
+	}
+	atomic.StoreInt32(&p.addressType, int32(request[3]))
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		_, _ = io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		host = readString()
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	atomic.AddInt32(&p.tunnels, 1)
+	go func() {
+		_, _ = io.Copy(target, conn)
+		target.Close()
+	}()
+	_, _ = io.Copy(conn, target)
+}
+
+func serverTLSConfig(server *apns2test.Server) *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate)
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+}
+
+func TestProxyConnect(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	proxy := newProxyStandIn(t, "http")
+	defer proxy.Close()
+	certificate := apns2test.NewCertificate()
+	server.TrustCertificate(certificate)
+
+	client, err := apns.NewClientWithOptions(
+		apns.WithCertificate(certificate),
+		apns.WithHost(server.URL),
+		apns.WithTLSConfig(serverTLSConfig(server)),
+		apns.WithProxy(proxy.URL),
+	)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	for i := 0; i < 2; i++ {
+		if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+			t.Fatal("Expected notification to be sent, found:", err)
+		}
+	}
+	if 1 != atomic.LoadInt32(&proxy.tunnels) {
+		t.Fatal("Expected:", 1, " found:", atomic.LoadInt32(&proxy.tunnels))
+	}
+}
+
+func TestProxyConnectSuccessStatus(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	proxy := newProxyStandIn(t, "http")
+	defer proxy.Close()
+	proxy.Established = "HTTP/1.1 204 No Content\r\n\r\n"
+	certificate := apns2test.NewCertificate()
+	server.TrustCertificate(certificate)
+
+	client, err := apns.NewClientWithOptions(
+		apns.WithCertificate(certificate),
+		apns.WithHost(server.URL),
+		apns.WithTLSConfig(serverTLSConfig(server)),
+		apns.WithProxy(proxy.URL),
+	)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+}
+
+func TestProxySOCKS5(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	proxy := newProxyStandIn(t, "socks5")
+	defer proxy.Close()
+	authToken := apns2test.NewToken()
+	server.TrustToken(authToken)
+
+	client, err := apns.NewClientWithOptions(
+		apns.WithToken(authToken),
+		apns.WithHost(server.URL),
+		apns.WithTLSConfig(serverTLSConfig(server)),
+		apns.WithProxy(proxy.URL),
+	)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	n := mockNotification()
+	n.Topic = "com.apns2test"
+	if res, err := client.Push(n); err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err, res)
+	}
+	if 1 != atomic.LoadInt32(&proxy.tunnels) {
+		t.Fatal("Expected:", 1, " found:", atomic.LoadInt32(&proxy.tunnels))
+	}
+}
+
+func TestProxySOCKS5Resolution(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "https://"))
+
+	// The proxy receives a domain name, address type 3, only with socks5h.
+	for scheme, remote := range map[string]bool{"socks5": false, "socks5h": true} {
+		proxy := newProxyStandIn(t, scheme)
+		defer proxy.Close()
+		client, err := apns.NewClientWithOptions(
+			apns.WithCertificate(apns2test.NewCertificate()),
+			apns.WithHost("https://localhost:"+port),
+			apns.WithTLSConfig(serverTLSConfig(server)),
+			apns.WithProxy(proxy.URL),
+		)
+		if err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+		_, _ = client.Push(mockNotification())
+		addressType := atomic.LoadInt32(&proxy.addressType)
+		if remote != (3 == addressType) || 0 == addressType {
+			t.Fatal("Unexpected address type for", scheme, ":", addressType)
+		}
+	}
+}
+
+func TestProxyUnauthorized(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	for _, scheme := range []string{"http", "socks5"} {
+		proxy := newProxyStandIn(t, scheme)
+		defer proxy.Close()
+		proxy.URL.User = url.UserPassword("user", "wrong")
+
+		client, err := apns.NewClientWithOptions(
+			apns.WithCertificate(apns2test.NewCertificate()),
+			apns.WithHost(server.URL),
+			apns.WithProxy(proxy.URL),
+		)
+		if err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+		if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrDial) {
+			t.Fatal("Expected:", apns.ErrDial, " found:", err)
+		}
+		if 0 != atomic.LoadInt32(&proxy.tunnels) {
+			t.Fatal("Expected:", 0, " found:", atomic.LoadInt32(&proxy.tunnels))
+		}
+	}
+}
+
+func TestProxyInvalid(t *testing.T) {
+	for _, proxyURL := range []string{"ftp://proxy:21", "http://"} {
+		u, _ := url.Parse(proxyURL)
+		if _, err := apns.NewClientWithOptions(apns.WithToken(mockToken()), apns.WithProxy(u)); err == nil {
+			t.Fatal("Expected error for proxy", proxyURL)
+		}
+	}
+}
+
+func TestProxyStalled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	for _, scheme := range []string{"http", "socks5"} {
+		client, err := apns.NewClientWithOptions(
+			apns.WithCertificate(apns2test.NewCertificate()),
+			apns.WithHost(apns.HostDevelopment),
+			apns.WithTLSDialTimeout(100*time.Millisecond),
+			apns.WithProxy(&url.URL{Scheme: scheme, Host: listener.Addr().String()}),
+		)
+		if err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+		start := time.Now()
+		if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrDial) {
+			t.Fatal("Expected:", apns.ErrDial, " found:", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatal("Expected the", scheme, "handshake to time out, took:", elapsed)
+		}
+	}
+}
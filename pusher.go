@@ -0,0 +1,10 @@
+package apns2
+
+// Pusher sends notifications to APNs. It is implemented by Client, and can be
+// replaced by a fake, such as apns2test.FakePusher, in unit tests.
+type Pusher interface {
+	Push(n *Notification) (*Response, error)
+	PushWithContext(ctx Context, n *Notification) (*Response, error)
+}
+
+var _ Pusher = (*Client)(nil)
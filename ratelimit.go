@@ -0,0 +1,215 @@
+package apns2
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited matches, with errors.Is, the RateLimitError returned when a
+// notification exceeds the RateLimiter of the Client.
+var ErrRateLimited = errors.New("apns2: rate limited")
+
+// RateLimitScope is the limit of a RateLimiter exceeded by a notification.
+type RateLimitScope string
+
+// The limits of a RateLimiter.
+const (
+	RateLimitClient      RateLimitScope = "client"
+	RateLimitTopic       RateLimitScope = "topic"
+	RateLimitDeviceToken RateLimitScope = "device_token"
+)
+
+// RateLimitError is returned when a notification cannot be sent without
+// exceeding the RateLimiter of the Client, either because the RateLimiter
+// fails fast or because the wait would outlast the context deadline.
+type RateLimitError struct {
+	Scope      RateLimitScope
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v by the %v limit, retry after %v", ErrRateLimited, e.Scope, e.RetryAfter)
+}
+
+// Is reports whether target is ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RateLimiter caps the pace of the requests sent by a Client with token
+// buckets. Requests exceeding a limit wait for their turn, or fail with a
+// RateLimitError if FailFast is set. Retries and the resend after a provider
+// token refresh count as requests, while the replays of the notifications that
+// APNs did not process do not. The exported fields must not be changed once
+// the RateLimiter is in use.
+type RateLimiter struct {
+	// Rate is the maximum number of requests per second sent by the Client.
+	// Zero means no limit.
+	Rate float64
+
+	// Burst is the number of requests which can be sent at once, above Rate
+	// and TopicRates. Values lower than 1 are treated as 1.
+	Burst int
+
+	// TopicRates are the maximum numbers of requests per second sent to each
+	// topic.
+	TopicRates map[string]float64
+
+	// DeviceTokenInterval is the minimum interval between two requests sent
+	// to the same device token. Zero means no limit.
+	DeviceTokenInterval time.Duration
+
+	// FailFast makes requests exceeding a limit fail immediately instead of
+	// waiting.
+	FailFast bool
+
+	mu           sync.Mutex
+	client       *bucket
+	topics       map[string]*bucket
+	deviceTokens map[string]time.Time
+	sweepAt      int
+}
+
+// NewRateLimiter returns a RateLimiter capping the Client to rate requests
+// per second.
+func NewRateLimiter(rate float64) *RateLimiter {
+	return &RateLimiter{Rate: rate}
+}
+
+// wait waits until the notification can be sent. If the limits would be
+// exceeded past the context deadline, or at all in FailFast mode, it returns a
+// RateLimitError without waiting.
+func (l *RateLimiter) wait(ctx Context, n *Notification) error {
+	maxDelay := time.Duration(math.MaxInt64)
+	if l.FailFast {
+		maxDelay = 0
+	} else if ctx != nil {
+		if deadline, ok := ctx.Deadline(); ok {
+			maxDelay = time.Until(deadline)
+		}
+	}
+	delay, err := l.reserve(n, time.Now(), maxDelay)
+	if err != nil {
+		return err
+	}
+	if delay <= 0 {
+		return nil
+	}
+	if ctx == nil {
+		time.Sleep(delay)
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token from every bucket the notification is subject to and
+// returns how long to wait before sending it. Nothing is taken if the wait
+// would be longer than maxDelay.
+func (l *RateLimiter) reserve(n *Notification, now time.Time, maxDelay time.Duration) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var (
+		delay   time.Duration
+		scope   RateLimitScope
+		buckets []*bucket
+	)
+	if l.Rate > 0 {
+		if l.client == nil {
+			l.client = newBucket(l.Rate, l.Burst, now)
+		}
+		buckets = append(buckets, l.client)
+		if d := l.client.delay(now); d > delay {
+			delay, scope = d, RateLimitClient
+		}
+	}
+	if rate := l.TopicRates[n.Topic]; rate > 0 {
+		if l.topics == nil {
+			l.topics = map[string]*bucket{}
+		}
+		b, ok := l.topics[n.Topic]
+		if !ok {
+			b = newBucket(rate, l.Burst, now)
+			l.topics[n.Topic] = b
+		}
+		buckets = append(buckets, b)
+		if d := b.delay(now); d > delay {
+			delay, scope = d, RateLimitTopic
+		}
+	}
+	if l.DeviceTokenInterval > 0 {
+		if next, ok := l.deviceTokens[n.DeviceToken]; ok && next.Sub(now) > delay {
+			delay, scope = next.Sub(now), RateLimitDeviceToken
+		}
+	}
+
+	if delay > maxDelay {
+		return 0, &RateLimitError{Scope: scope, RetryAfter: delay}
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	if l.DeviceTokenInterval > 0 {
+		l.sweepDeviceTokens(now)
+		l.deviceTokens[n.DeviceToken] = now.Add(delay + l.DeviceTokenInterval)
+	}
+	return delay, nil
+}
+
+// sweepDeviceTokens forgets the device tokens which can be sent to again, once
+// their number doubled since the last sweep.
+func (l *RateLimiter) sweepDeviceTokens(now time.Time) {
+	if l.deviceTokens == nil {
+		l.deviceTokens = map[string]time.Time{}
+	}
+	if len(l.deviceTokens) < l.sweepAt {
+		return
+	}
+	for deviceToken, next := range l.deviceTokens {
+		if !next.After(now) {
+			delete(l.deviceTokens, deviceToken)
+		}
+	}
+	l.sweepAt = 2 * len(l.deviceTokens)
+	if l.sweepAt < 1024 {
+		l.sweepAt = 1024
+	}
+}
+
+// bucket is a token bucket. Its tokens go negative when requests are
+// reserved ahead of time.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int, now time.Time) *bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// delay refills the bucket and returns how long until a token is available.
+func (b *bucket) delay(now time.Time) time.Duration {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
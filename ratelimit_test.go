@@ -0,0 +1,145 @@
+package apns2_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+func TestRateLimiterWaits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := mockClient(server.URL)
+	client.RateLimiter = apns.NewRateLimiter(100)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if res, err := client.Push(mockNotification()); err != nil || !res.Sent() {
+			t.Fatal("Expected notification to be sent, found:", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatal("Expected pushes to be paced, found:", elapsed)
+	}
+}
+
+func TestRateLimiterFailFast(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := mockClient(server.URL)
+	client.RateLimiter = &apns.RateLimiter{
+		Rate:     1,
+		Burst:    2,
+		FailFast: true,
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Push(mockNotification()); err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+	}
+	_, err := client.Push(mockNotification())
+	var rateLimitErr *apns.RateLimitError
+	if !errors.Is(err, apns.ErrRateLimited) || !errors.As(err, &rateLimitErr) {
+		t.Fatal("Expected:", apns.ErrRateLimited, " found:", err)
+	}
+	if apns.RateLimitClient != rateLimitErr.Scope || rateLimitErr.RetryAfter <= 0 || rateLimitErr.RetryAfter > time.Second {
+		t.Fatal("Unexpected error:", rateLimitErr.Scope, rateLimitErr.RetryAfter)
+	}
+}
+
+func TestRateLimiterTopic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := mockClient(server.URL)
+	client.RateLimiter = &apns.RateLimiter{
+		TopicRates: map[string]float64{"com.marketing": 1},
+		FailFast:   true,
+	}
+
+	n := mockNotification()
+	n.Topic = "com.marketing"
+	if _, err := client.Push(n); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	var rateLimitErr *apns.RateLimitError
+	if _, err := client.Push(n); !errors.As(err, &rateLimitErr) || apns.RateLimitTopic != rateLimitErr.Scope {
+		t.Fatal("Expected topic limit, found:", err)
+	}
+	n.Topic = "com.transactional"
+	for i := 0; i < 3; i++ {
+		if _, err := client.Push(n); err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+	}
+}
+
+func TestRateLimiterDeviceToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := mockClient(server.URL)
+	client.RateLimiter = &apns.RateLimiter{DeviceTokenInterval: time.Hour}
+
+	if _, err := client.Push(mockNotification()); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	other := mockNotification()
+	other.DeviceToken = "22bb01229f15f0f0c52029d8cf8cd0aeaf2365fe4cebc4af26cd6d76b7919ef7"
+	if _, err := client.Push(other); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := client.PushWithContext(ctx, mockNotification())
+	var rateLimitErr *apns.RateLimitError
+	if !errors.As(err, &rateLimitErr) || apns.RateLimitDeviceToken != rateLimitErr.Scope {
+		t.Fatal("Expected device token limit, found:", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatal("Expected to fail without waiting for the deadline, found:", elapsed)
+	}
+}
+
+func TestRateLimiterContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := mockClient(server.URL)
+	client.RateLimiter = apns.NewRateLimiter(0.1)
+
+	if _, err := client.Push(mockNotification()); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := client.PushWithContext(ctx, mockNotification()); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected:", context.Canceled, " found:", err)
+	}
+}
+
+func TestRateLimiterTokenRefreshResend(t *testing.T) {
+	token := mockToken()
+	_, _ = token.Generate()
+	token.IssuedAt = time.Now().Add(-30 * time.Minute).Unix()
+	stale := "bearer " + token.Bearer
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if stale == r.Header.Get("authorization") {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"reason":"ExpiredProviderToken"}`))
+		}
+	}))
+	defer server.Close()
+	client := mockClient(server.URL)
+	client.Token = token
+	client.RateLimiter = &apns.RateLimiter{Rate: 1, FailFast: true}
+
+	if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrRateLimited) {
+		t.Fatal("Expected:", apns.ErrRateLimited, " found:", err)
+	}
+}
@@ -0,0 +1,43 @@
+package apns2
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sync/atomic"
+)
+
+// MaxReplays is the default Client.MaxReplays of the clients created by
+// NewClient, NewTokenClient and NewClientWithOptions.
+var MaxReplays = 3
+
+// replay performs the round trip, replaying the notification while it was
+// not processed by APNs. The connection which received the GOAWAY frame is
+// drained by the transport and the replays are sent over a new one.
+func (c *Client) replay(ctx Context, n *Notification, payload []byte, bearer, host string) (*Response, error) {
+	for replays := 0; ; replays++ {
+		response, err := c.roundTrip(ctx, n, payload, bearer, host)
+		if err == nil || replays >= c.MaxReplays || !unprocessed(err) || (ctx != nil && ctx.Err() != nil) {
+			if response != nil {
+				response.Replays += replays
+			}
+			return response, err
+		}
+	}
+}
+
+// withReplayCount returns a context counting in replays the times the HTTP/2
+// transport sent the request again over a new connection, which it does by
+// itself for the requests rejected by a GOAWAY frame.
+func withReplayCount(ctx Context, replays *int32) Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var conns int32
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			if atomic.AddInt32(&conns, 1) > 1 {
+				atomic.AddInt32(replays, 1)
+			}
+		},
+	})
+}
@@ -0,0 +1,183 @@
+package apns2_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	apns "github.com/sapienzaapps/apns2"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// goAwayServer is a cleartext HTTP/2 server answering the first goAways
+// requests it receives with a GOAWAY frame whose last stream ID is 0, which
+// tells the client the requests were not processed.
+type goAwayServer struct {
+	listener net.Listener
+	goAways  int32
+	conns    int32
+}
+
+func newGoAwayServer(t *testing.T, goAways int32) *goAwayServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &goAwayServer{listener: listener, goAways: goAways}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&s.conns, 1)
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *goAwayServer) serve(conn net.Conn) {
+	defer conn.Close()
+	if _, err := io.ReadFull(conn, make([]byte, len(http2.ClientPreface))); err != nil {
+		return
+	}
+	fr := http2.NewFramer(conn, conn)
+	if err := fr.WriteSettings(); err != nil {
+		return
+	}
+	goneAway := false
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			return
+		}
+		switch f := f.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				_ = fr.WriteSettingsAck()
+			}
+		case *http2.HeadersFrame:
+			if !goneAway && atomic.AddInt32(&s.goAways, -1) >= 0 {
+				_ = fr.WriteGoAway(0, http2.ErrCodeNo, nil)
+				goneAway = true
+			}
+		case *http2.DataFrame:
+			if f.StreamEnded() && !goneAway {
+				var block bytes.Buffer
+				encoder := hpack.NewEncoder(&block)
+				_ = encoder.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+				_ = encoder.WriteField(hpack.HeaderField{Name: "apns-id", Value: "replayed"})
+				_ = fr.WriteHeaders(http2.HeadersFrameParam{
+					StreamID:      f.StreamID,
+					BlockFragment: block.Bytes(),
+					EndHeaders:    true,
+					EndStream:     true,
+				})
+			}
+		}
+	}
+}
+
+func (s *goAwayServer) URL() string {
+	return "http://" + s.listener.Addr().String()
+}
+
+func (s *goAwayServer) Close() {
+	s.listener.Close()
+}
+
+// connTransport sends requests over a single HTTP/2 connection, dialing a new
+// one when it cannot take new requests, without retrying them.
+type connTransport struct {
+	transport *http2.Transport
+	addr      string
+	mu        sync.Mutex
+	cc        *http2.ClientConn
+}
+
+func (c *connTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	if c.cc == nil || !c.cc.CanTakeNewRequest() {
+		conn, err := net.Dial("tcp", c.addr)
+		if err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+		if c.cc, err = c.transport.NewClientConn(conn); err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+	}
+	cc := c.cc
+	c.mu.Unlock()
+	return cc.RoundTrip(req)
+}
+
+func TestReplayAfterGoAway(t *testing.T) {
+	server := newGoAwayServer(t, 1)
+	defer server.Close()
+	client := mockClient(server.URL())
+	client.MaxReplays = apns.MaxReplays
+	client.HTTPClient.Transport = &connTransport{
+		transport: &http2.Transport{AllowHTTP: true},
+		addr:      server.listener.Addr().String(),
+	}
+
+	res, err := client.Push(mockNotification())
+	if err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	if 1 != res.Replays || 1 != res.Attempts || "replayed" != res.ApnsID {
+		t.Fatal("Expected 1 replay in 1 attempt, found:", res.Replays, res.Attempts, res.ApnsID)
+	}
+	if 2 != atomic.LoadInt32(&server.conns) {
+		t.Fatal("Expected:", 2, " found:", atomic.LoadInt32(&server.conns))
+	}
+}
+
+func TestReplayByTransport(t *testing.T) {
+	server := newGoAwayServer(t, 1)
+	defer server.Close()
+	client := mockClient(server.URL())
+	client.MaxReplays = apns.MaxReplays
+	client.HTTPClient.Transport = &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}
+
+	res, err := client.Push(mockNotification())
+	if err != nil || !res.Sent() {
+		t.Fatal("Expected notification to be sent, found:", err)
+	}
+	if 1 != res.Replays {
+		t.Fatal("Expected:", 1, " found:", res.Replays)
+	}
+}
+
+func TestReplayLimit(t *testing.T) {
+	server := newGoAwayServer(t, 100)
+	defer server.Close()
+	client := mockClient(server.URL())
+	client.MaxReplays = apns.MaxReplays
+	client.HTTPClient.Transport = &connTransport{
+		transport: &http2.Transport{AllowHTTP: true},
+		addr:      server.listener.Addr().String(),
+	}
+
+	if _, err := client.Push(mockNotification()); !errors.Is(err, apns.ErrGoAway) {
+		t.Fatal("Expected:", apns.ErrGoAway, " found:", err)
+	}
+	if int32(apns.MaxReplays+1) != atomic.LoadInt32(&server.conns) {
+		t.Fatal("Expected:", apns.MaxReplays+1, " found:", atomic.LoadInt32(&server.conns))
+	}
+}
@@ -129,6 +129,20 @@ type Response struct {
 	// If the value of StatusCode is 410, this is the last time at which APNs
 	// confirmed that the device token was no longer valid for the topic.
 	Timestamp Time
+
+	// The number of attempts made to deliver the notification, as governed by
+	// the Client RetryPolicy. The transparent resend following a provider token
+	// regeneration is not counted as a separate attempt.
+	Attempts int `json:"-"`
+
+	// The number of times the notification was sent again over a new
+	// connection because APNs did not process it, after a GOAWAY frame or a
+	// REFUSED_STREAM reset. Replays are not counted as attempts.
+	Replays int `json:"-"`
+
+	// The endpoint which served the notification, such as HostProduction or
+	// one of the Client FallbackHosts.
+	Host string `json:"-"`
 }
 
 // Sent returns whether or not the notification was successfully sent.
@@ -137,6 +151,21 @@ func (c *Response) Sent() bool {
 	return c.StatusCode == StatusSent
 }
 
+// Err returns nil if the notification was successfully sent, otherwise an
+// *APNsError describing the rejection, which can be compared with errors.Is
+// to the sentinel error of the Reason, such as ErrUnregistered.
+func (c *Response) Err() error {
+	if c.Sent() {
+		return nil
+	}
+	return &APNsError{
+		StatusCode: c.StatusCode,
+		Reason:     c.Reason,
+		ApnsID:     c.ApnsID,
+		Timestamp:  c.Timestamp,
+	}
+}
+
 // Time represents a device uninstall time
 type Time struct {
 	time.Time
@@ -0,0 +1,112 @@
+package apns2
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how a Client retries a notification which failed with
+// a transient error. A nil RetryPolicy disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a notification is sent,
+	// including the first attempt. Values lower than 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between two consecutive attempts. Set zero for
+	// no limit.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor by which the delay grows after each attempt.
+	// Values lower than 1 are treated as 1.
+	Multiplier float64
+
+	// Jitter is the fraction of each delay, between 0 and 1, which is
+	// randomized. With a Jitter of 0.2 a 1s delay becomes a random delay
+	// between 800ms and 1s.
+	Jitter float64
+
+	// Reasons is the set of APNs Reason values which are considered
+	// transient and therefore retried.
+	Reasons map[string]bool
+
+	// RetryTransportErrors enables retries when no response was received from
+	// APNs, for example when the connection was lost. Context cancellation
+	// and deadline errors are never retried.
+	RetryTransportErrors bool
+}
+
+// NewRetryPolicy returns a RetryPolicy with sensible defaults: up to 3
+// attempts, an exponential backoff starting at 100ms and capped at 5s with
+// 20% jitter, retrying transport errors and the ReasonInternalServerError,
+// ReasonServiceUnavailable, ReasonShutdown and ReasonTooManyRequests
+// responses.
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		Reasons: map[string]bool{
+			ReasonInternalServerError: true,
+			ReasonServiceUnavailable:  true,
+			ReasonShutdown:            true,
+			ReasonTooManyRequests:     true,
+		},
+		RetryTransportErrors: true,
+	}
+}
+
+// Retryable reports whether the outcome of an attempt, as returned by a
+// single round trip to APNs, should be retried according to the policy.
+func (p *RetryPolicy) Retryable(res *Response, err error) bool {
+	if err != nil {
+		if res != nil || !p.RetryTransportErrors {
+			return false
+		}
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return res != nil && p.Reasons[res.Reason]
+}
+
+// Backoff returns the delay to wait after the given attempt, starting from 1,
+// before sending the notification again.
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := math.Max(p.Multiplier, 1)
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff -= backoff * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// wait sleeps for the backoff following the given attempt. It returns false
+// without waiting if the context deadline would expire before the next
+// attempt, or as soon as the context is done.
+func (p *RetryPolicy) wait(ctx Context, attempt int) bool {
+	backoff := p.Backoff(attempt)
+	if ctx == nil {
+		time.Sleep(backoff)
+		return true
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+		return false
+	}
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,220 @@
+package apns2_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+func mockRetryPolicy() *apns.RetryPolicy {
+	policy := apns.NewRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 2 * time.Millisecond
+	return policy
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	policy := apns.NewRetryPolicy()
+	if 3 != policy.MaxAttempts {
+		t.Fatal("Expected:", 3, " found:", policy.MaxAttempts)
+	}
+	for _, reason := range []string{apns.ReasonInternalServerError, apns.ReasonServiceUnavailable, apns.ReasonShutdown, apns.ReasonTooManyRequests} {
+		if !policy.Reasons[reason] {
+			t.Fatal("Expected reason to be retryable:", reason)
+		}
+	}
+	if policy.Reasons[apns.ReasonBadDeviceToken] {
+		t.Fatal("Expected reason not to be retryable:", apns.ReasonBadDeviceToken)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &apns.RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	for i, backoff := range expected {
+		if backoff != policy.Backoff(i+1) {
+			t.Fatal("Expected:", backoff, " found:", policy.Backoff(i+1))
+		}
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	policy := &apns.RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.5,
+	}
+	for i := 0; i < 100; i++ {
+		backoff := policy.Backoff(2)
+		if backoff < 100*time.Millisecond || backoff > 200*time.Millisecond {
+			t.Fatal("Expected backoff between 100ms and 200ms, found:", backoff)
+		}
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	policy := apns.NewRetryPolicy()
+	if !policy.Retryable(&apns.Response{StatusCode: 503, Reason: apns.ReasonShutdown}, nil) {
+		t.Fatal("Expected Shutdown to be retryable")
+	}
+	if policy.Retryable(&apns.Response{StatusCode: 410, Reason: apns.ReasonUnregistered}, nil) {
+		t.Fatal("Expected Unregistered not to be retryable")
+	}
+	if !policy.Retryable(nil, errors.New("connection reset")) {
+		t.Fatal("Expected transport error to be retryable")
+	}
+	if policy.Retryable(nil, context.DeadlineExceeded) {
+		t.Fatal("Expected deadline exceeded not to be retryable")
+	}
+	policy.RetryTransportErrors = false
+	if policy.Retryable(nil, errors.New("connection reset")) {
+		t.Fatal("Expected transport error not to be retryable")
+	}
+}
+
+func TestClientRetryTransientReason(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"reason":"ServiceUnavailable"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.RetryPolicy = mockRetryPolicy()
+	res, err := client.Push(mockNotification())
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if !res.Sent() {
+		t.Fatal("Expected:", true, " found:", res.Sent())
+	}
+	if 3 != res.Attempts {
+		t.Fatal("Expected:", 3, " found:", res.Attempts)
+	}
+}
+
+func TestClientRetryMaxAttempts(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"reason":"InternalServerError"}`))
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.RetryPolicy = mockRetryPolicy()
+	client.RetryPolicy.MaxAttempts = 4
+	res, err := client.Push(mockNotification())
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if apns.ReasonInternalServerError != res.Reason {
+		t.Fatal("Expected:", apns.ReasonInternalServerError, " found:", res.Reason)
+	}
+	if 4 != res.Attempts || 4 != atomic.LoadInt32(&requests) {
+		t.Fatal("Expected:", 4, " found:", res.Attempts, requests)
+	}
+}
+
+func TestClientRetryPermanentReason(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.RetryPolicy = mockRetryPolicy()
+	res, err := client.Push(mockNotification())
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if 1 != res.Attempts || 1 != atomic.LoadInt32(&requests) {
+		t.Fatal("Expected:", 1, " found:", res.Attempts, requests)
+	}
+}
+
+func TestClientRetryResendsPayload(t *testing.T) {
+	var requests int32
+	n := mockNotification()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, 64)
+		size, _ := r.Body.Read(body)
+		if string(n.Payload.([]byte)) != string(body[:size]) {
+			t.Error("Expected:", string(n.Payload.([]byte)), " found:", string(body[:size]))
+		}
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"reason":"TooManyRequests"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.RetryPolicy = mockRetryPolicy()
+	res, err := client.Push(n)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if 2 != res.Attempts {
+		t.Fatal("Expected:", 2, " found:", res.Attempts)
+	}
+}
+
+func TestClientRetryHonorsContextDeadline(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"reason":"Shutdown"}`))
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.RetryPolicy = mockRetryPolicy()
+	client.RetryPolicy.InitialBackoff = time.Minute
+	client.RetryPolicy.MaxBackoff = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, err := client.PushWithContext(ctx, mockNotification())
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if 1 != res.Attempts || 1 != atomic.LoadInt32(&requests) {
+		t.Fatal("Expected:", 1, " found:", res.Attempts, requests)
+	}
+}
+
+func TestClientNoRetryPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"reason":"Shutdown"}`))
+	}))
+	defer server.Close()
+
+	res, err := mockClient(server.URL).Push(mockNotification())
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if 1 != res.Attempts {
+		t.Fatal("Expected:", 1, " found:", res.Attempts)
+	}
+}
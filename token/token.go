@@ -18,6 +18,12 @@ const (
 	// rejects subsequent push messages. This is set to under an hour so that
 	// we generate a new token before the existing one expires.
 	TokenTimeout = 3000
+
+	// TokenRefreshInterval is the minimum period of time in seconds between
+	// the issue of a token and its forced regeneration with Refresh. APNs
+	// rejects providers updating their token more than once every 20 minutes
+	// with a TooManyProviderTokenUpdates error.
+	TokenRefreshInterval = 1200
 )
 
 // Possible errors when parsing a .p8 file.
@@ -69,16 +75,43 @@ func AuthKeyFromBytes(bytes []byte) (*ecdsa.PrivateKey, error) {
 // GenerateIfExpired checks to see if the token is about to expire and
 // generates a new token.
 func (t *Token) GenerateIfExpired() (bearer string) {
+	bearer, _, err := t.Renew()
+	if err != nil {
+		// It should never panic here, as Generate should fail with error only the first time
+		panic(err)
+	}
+	return bearer
+}
+
+// Renew returns the bearer of the token, generating a new one first if it is
+// about to expire. It reports whether a new bearer was generated.
+func (t *Token) Renew() (bearer string, renewed bool, err error) {
+	t.Lock()
+	defer t.Unlock()
+	if !t.Expired() {
+		return t.Bearer, false, nil
+	}
+	if _, err := t.Generate(); err != nil {
+		return "", false, err
+	}
+	return t.Bearer, true, nil
+}
+
+// Refresh forces the generation of a new token, bypassing the TokenTimeout
+// check, after APNs rejected the stale bearer. If the token was already
+// regenerated since stale was issued, for example by a concurrent call, or if
+// it was issued less than TokenRefreshInterval seconds ago, the current bearer
+// is returned unchanged.
+func (t *Token) Refresh(stale string) (bearer string, err error) {
 	t.Lock()
 	defer t.Unlock()
-	if t.Expired() {
-		_, err := t.Generate()
-		if err != nil {
-			// It should never panic here, as Generate should fail with error only the first time
-			panic(err)
-		}
+	if t.Bearer != stale || time.Now().Unix() < t.IssuedAt+TokenRefreshInterval {
+		return t.Bearer, nil
+	}
+	if _, err := t.Generate(); err != nil {
+		return "", err
 	}
-	return t.Bearer
+	return t.Bearer, nil
 }
 
 // Expired checks to see if the token has expired.
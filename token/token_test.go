@@ -121,3 +121,77 @@ func TestGenerateWithInvalidAuthKey(t *testing.T) {
 		t.Fatal("Expected error, found nil")
 	}
 }
+
+func TestRefresh(t *testing.T) {
+	authKey, _ := token.AuthKeyFromFile("_fixtures/authkey-valid.p8")
+	token := &token.Token{
+		AuthKey: authKey,
+	}
+	_, _ = token.Generate()
+	token.IssuedAt = time.Now().Add(-30 * time.Minute).Unix()
+	stale := token.Bearer
+	bearer, err := token.Refresh(stale)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if bearer == stale {
+		t.Fatal("Expected a new bearer")
+	}
+	if time.Now().Unix() != token.IssuedAt {
+		t.Fatal("Expected:", token.IssuedAt, " found:", time.Now().Unix())
+	}
+}
+
+func TestRefreshAlreadyRefreshed(t *testing.T) {
+	authKey, _ := token.AuthKeyFromFile("_fixtures/authkey-valid.p8")
+	token := &token.Token{
+		AuthKey: authKey,
+	}
+	_, _ = token.Generate()
+	token.IssuedAt = time.Now().Add(-30 * time.Minute).Unix()
+	current := token.Bearer
+	bearer, err := token.Refresh("stale")
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if bearer != current {
+		t.Fatal("Expected:", current, " found:", bearer)
+	}
+}
+
+func TestRefreshTooSoon(t *testing.T) {
+	authKey, _ := token.AuthKeyFromFile("_fixtures/authkey-valid.p8")
+	token := &token.Token{
+		AuthKey: authKey,
+	}
+	_, _ = token.Generate()
+	stale := token.Bearer
+	bearer, err := token.Refresh(stale)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if bearer != stale {
+		t.Fatal("Expected:", stale, " found:", bearer)
+	}
+}
+
+func TestRenew(t *testing.T) {
+	authKey, _ := token.AuthKeyFromFile("_fixtures/authkey-valid.p8")
+	token := &token.Token{
+		AuthKey: authKey,
+	}
+	bearer, renewed, err := token.Renew()
+	if err != nil || !renewed || bearer != token.Bearer {
+		t.Fatal("Expected a new bearer, found:", renewed, err)
+	}
+	if again, renewed, _ := token.Renew(); renewed || again != bearer {
+		t.Fatal("Expected the same bearer, found:", renewed)
+	}
+}
+
+func TestRenewWithNoAuthKey(t *testing.T) {
+	token := &token.Token{}
+	if _, renewed, err := token.Renew(); renewed || err == nil {
+		t.Fatal("Expected error, found:", err)
+	}
+}
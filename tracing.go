@@ -0,0 +1,141 @@
+package apns2
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+)
+
+// Tracer starts the span of every notification pushed by a Client. It has the
+// shape of the OpenTelemetry trace.Tracer, so that adapting one takes a few
+// lines.
+type Tracer interface {
+	// Start creates a span named spanName, child of the span in ctx if any,
+	// and returns a context holding it.
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer. Events may be added from the goroutines
+// of the HTTP/2 transport, so implementations must be safe for concurrent use.
+type Span interface {
+	SetAttributes(attributes ...Attribute)
+	AddEvent(name string, attributes ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key-value pair describing a span or an event.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// SpanName is the name of the spans started by the Client Tracer.
+const SpanName = "apns2.Push"
+
+// Names of the events added to the spans, as the request to APNs progresses.
+const (
+	EventConnectionAcquire  = "connection.acquire"
+	EventConnectionAcquired = "connection.acquired"
+	EventTLSHandshake       = "tls.handshake"
+	EventRequestWritten     = "request.written"
+	EventFirstResponseByte  = "response.first_byte"
+)
+
+type spanKey struct{}
+
+// startSpan starts the span of the notification, if the Client has a Tracer.
+// The returned function ends it, recording the outcome of the push.
+func (c *Client) startSpan(ctx Context, n *Notification) (Context, func(*Response, error)) {
+	if c.Tracer == nil {
+		return ctx, func(*Response, error) {}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := c.Tracer.Start(ctx, SpanName)
+	ctx = context.WithValue(ctx, spanKey{}, span)
+
+	attributes := []Attribute{
+		{Key: "apns.device_token_hash", Value: hashDeviceToken(n.DeviceToken)},
+		{Key: "apns.topic", Value: n.Topic},
+	}
+	if n.PushType != "" {
+		attributes = append(attributes, Attribute{Key: "apns.push_type", Value: string(n.PushType)})
+	}
+	if n.Priority > 0 {
+		attributes = append(attributes, Attribute{Key: "apns.priority", Value: n.Priority})
+	}
+	span.SetAttributes(attributes...)
+
+	return ctx, func(res *Response, err error) {
+		if res != nil {
+			span.SetAttributes(
+				Attribute{Key: "apns.id", Value: res.ApnsID},
+				Attribute{Key: "http.status_code", Value: res.StatusCode},
+				Attribute{Key: "apns.reason", Value: res.Reason},
+				Attribute{Key: "apns.attempts", Value: res.Attempts},
+				Attribute{Key: "apns.host", Value: res.Host},
+			)
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+}
+
+// withClientTrace returns a context adding the connection and response events
+// of the request to the span in ctx, if any.
+func withClientTrace(ctx Context) Context {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return ctx
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			span.AddEvent(EventConnectionAcquire, Attribute{Key: "net.peer", Value: hostPort})
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			span.AddEvent(EventConnectionAcquired,
+				Attribute{Key: "net.conn.reused", Value: info.Reused},
+				Attribute{Key: "net.conn.was_idle", Value: info.WasIdle},
+			)
+			// The HTTP/2 transport does not report the TLS handshake, which
+			// is timed by DialTLS instead.
+			if conn, ok := info.Conn.(*trackedConn); ok && !info.Reused {
+				state := conn.ConnectionState()
+				span.AddEvent(EventTLSHandshake,
+					Attribute{Key: "net.dial.duration", Value: conn.connected.Sub(conn.dialStart)},
+					Attribute{Key: "tls.handshake.duration", Value: conn.handshakeDone.Sub(conn.connected)},
+					Attribute{Key: "tls.version", Value: tlsVersion(state.Version)},
+					Attribute{Key: "tls.resumed", Value: state.DidResume},
+				)
+			}
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err != nil {
+				span.AddEvent(EventRequestWritten, Attribute{Key: "error", Value: info.Err.Error()})
+				return
+			}
+			span.AddEvent(EventRequestWritten)
+		},
+		GotFirstResponseByte: func() {
+			span.AddEvent(EventFirstResponseByte)
+		},
+	})
+}
+
+func tlsVersion(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	}
+	return ""
+}
@@ -0,0 +1,181 @@
+package apns2_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"testing"
+
+	apns "github.com/sapienzaapps/apns2"
+	"github.com/sapienzaapps/apns2/apns2test"
+)
+
+type mockTracer struct {
+	mu    sync.Mutex
+	spans []*mockSpan
+}
+
+func (t *mockTracer) Start(ctx context.Context, spanName string) (context.Context, apns.Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &mockSpan{name: spanName, attributes: map[string]interface{}{}}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+type mockSpan struct {
+	mu         sync.Mutex
+	name       string
+	attributes map[string]interface{}
+	events     []string
+	errs       []error
+	ended      bool
+}
+
+func (s *mockSpan) SetAttributes(attributes ...apns.Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attributes {
+		s.attributes[a.Key] = a.Value
+	}
+}
+
+func (s *mockSpan) AddEvent(name string, attributes ...apns.Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, name)
+}
+
+func (s *mockSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, err)
+}
+
+func (s *mockSpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+func (s *mockSpan) count(event string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, e := range s.events {
+		if e == event {
+			count++
+		}
+	}
+	return count
+}
+
+func TestTracerSpan(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	tracer := &mockTracer{}
+	client := server.Client(apns2test.NewCertificate())
+	client.Tracer = tracer
+
+	n := mockNotification()
+	n.PushType = apns.PushTypeAlert
+	n.Priority = apns.PriorityHigh
+	res, err := client.Push(n)
+	if err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if 1 != len(tracer.spans) {
+		t.Fatal("Expected:", 1, " found:", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if apns.SpanName != span.name || !span.ended {
+		t.Fatal("Expected ended span", apns.SpanName, " found:", span.name, span.ended)
+	}
+	hash := sha256.Sum256([]byte(n.DeviceToken))
+	expected := map[string]interface{}{
+		"apns.device_token_hash": hex.EncodeToString(hash[:]),
+		"apns.topic":             n.Topic,
+		"apns.push_type":         "alert",
+		"apns.priority":          apns.PriorityHigh,
+		"apns.id":                res.ApnsID,
+		"http.status_code":       200,
+		"apns.reason":            "",
+		"apns.attempts":          1,
+	}
+	for key, value := range expected {
+		if value != span.attributes[key] {
+			t.Fatal("Expected", key, ":", value, " found:", span.attributes[key])
+		}
+	}
+	for _, event := range []string{apns.EventConnectionAcquire, apns.EventConnectionAcquired, apns.EventTLSHandshake, apns.EventFirstResponseByte} {
+		if 1 != span.count(event) {
+			t.Fatal("Expected event", event, " found:", span.events)
+		}
+	}
+
+	if _, err := client.Push(n); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if span := tracer.spans[1]; 0 != span.count(apns.EventTLSHandshake) || 1 != span.count(apns.EventConnectionAcquired) {
+		t.Fatal("Expected a reused connection, found:", span.events)
+	}
+}
+
+func TestTracerSpanRejected(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	tracer := &mockTracer{}
+	client := server.Client(apns2test.NewCertificate())
+	client.Tracer = tracer
+
+	n := mockNotification()
+	server.RejectDeviceToken(n.DeviceToken, apns.ReasonBadDeviceToken)
+	if _, err := client.Push(n); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	span := tracer.spans[0]
+	if 400 != span.attributes["http.status_code"] || apns.ReasonBadDeviceToken != span.attributes["apns.reason"] {
+		t.Fatal("Expected rejection attributes, found:", span.attributes)
+	}
+	if _, ok := span.attributes["apns.push_type"]; ok {
+		t.Fatal("Expected no push type attribute, found:", span.attributes["apns.push_type"])
+	}
+}
+
+func TestTracerSpanError(t *testing.T) {
+	tracer := &mockTracer{}
+	client := mockClient("http://127.0.0.1:1")
+	client.Tracer = tracer
+	_, err := client.Push(mockNotification())
+	if err == nil {
+		t.Fatal("Expected error, found nil")
+	}
+	span := tracer.spans[0]
+	if !span.ended || 1 != len(span.errs) || !errors.Is(span.errs[0], err) {
+		t.Fatal("Expected recorded error", err, " found:", span.errs)
+	}
+}
+
+func TestTracerSpanConnPool(t *testing.T) {
+	server := apns2test.NewServer()
+	defer server.Close()
+	tracer := &mockTracer{}
+	client := server.Client(apns2test.NewCertificate()).WithConnPool(2, apns.PoolLeastInFlight)
+	client.Tracer = tracer
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Push(mockNotification()); err != nil {
+			t.Fatal("Expected no error, found:", err)
+		}
+	}
+	for _, event := range []string{apns.EventConnectionAcquire, apns.EventConnectionAcquired, apns.EventTLSHandshake} {
+		if span := tracer.spans[0]; 1 != span.count(event) {
+			t.Fatal("Expected event", event, " found:", span.events)
+		}
+	}
+	if span := tracer.spans[1]; 0 != span.count(apns.EventTLSHandshake) || 1 != span.count(apns.EventConnectionAcquired) {
+		t.Fatal("Expected a reused connection, found:", span.events)
+	}
+}
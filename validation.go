@@ -0,0 +1,147 @@
+package apns2
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// APNs limits checked by Notification.Validate.
+const (
+	// MaxPayloadSize is the maximum size in bytes of a notification payload.
+	MaxPayloadSize = 4096
+
+	// MaxVOIPPayloadSize is the maximum size in bytes of a VoIP notification
+	// payload.
+	MaxVOIPPayloadSize = 5120
+
+	// MaxCollapseIDSize is the maximum size in bytes of a collapse identifier.
+	MaxCollapseIDSize = 64
+
+	// MinDeviceTokenLength and MaxDeviceTokenLength bound the number of
+	// hexadecimal digits of a device token.
+	MinDeviceTokenLength = 64
+	MaxDeviceTokenLength = 200
+)
+
+// topicSuffixes holds the suffix the apns-topic must end with for the push
+// types which require one.
+var topicSuffixes = map[EPushType]string{
+	PushTypeVOIP:         ".voip",
+	PushTypeComplication: ".complication",
+	PushTypeFileProvider: ".pushkit.fileprovider",
+}
+
+// ValidationError describes a Notification field violating the APNs rules.
+// Reason is the APNs Reason the notification would be rejected with, and the
+// error matches its sentinel error with errors.Is.
+type ValidationError struct {
+	Field   string
+	Reason  string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("apns2: invalid %v: %v", e.Field, e.Message)
+}
+
+// Unwrap returns the sentinel error of the Reason.
+func (e *ValidationError) Unwrap() error {
+	return reasonErrors[e.Reason]
+}
+
+// ValidationErrors lists every violation found by Notification.Validate.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is reports whether any of the violations matches target, so that
+// errors.Is(err, ErrBadPriority) holds when the priority is one of them.
+func (e ValidationErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first violation matching target, so that errors.As can
+// extract a *ValidationError.
+func (e ValidationErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the Notification against the rules enforced by APNs, so
+// that invalid notifications can be caught without a network round trip. It
+// returns nil or ValidationErrors listing every violation.
+func (n *Notification) Validate() error {
+	payload, err := n.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if errs := n.validate(payload); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (n *Notification) validate(payload []byte) ValidationErrors {
+	var errs ValidationErrors
+	violation := func(field, reason, format string, args ...interface{}) {
+		errs = append(errs, &ValidationError{Field: field, Reason: reason, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch _, err := hex.DecodeString(n.DeviceToken); {
+	case n.DeviceToken == "":
+		violation("DeviceToken", ReasonMissingDeviceToken, "device token is empty")
+	case err != nil:
+		violation("DeviceToken", ReasonBadDeviceToken, "device token is not an even-length hexadecimal string")
+	case len(n.DeviceToken) < MinDeviceTokenLength || len(n.DeviceToken) > MaxDeviceTokenLength:
+		violation("DeviceToken", ReasonBadDeviceToken, "device token length %v is not between %v and %v", len(n.DeviceToken), MinDeviceTokenLength, MaxDeviceTokenLength)
+	}
+
+	if len(n.CollapseID) > MaxCollapseIDSize {
+		violation("CollapseID", ReasonBadCollapseID, "collapse id is %v bytes, exceeding %v", len(n.CollapseID), MaxCollapseIDSize)
+	}
+
+	if n.ApnsID != "" && !ValidApnsID(n.ApnsID) {
+		violation("ApnsID", ReasonBadMessageID, "%q is not a canonical UUID", n.ApnsID)
+	}
+
+	if n.Priority != 0 && n.Priority != 1 && n.Priority != PriorityLow && n.Priority != PriorityHigh {
+		violation("Priority", ReasonBadPriority, "priority %v is not one of 1, 5 or 10", n.Priority)
+	}
+	if n.PushType == PushTypeBackground && n.Priority == PriorityHigh {
+		violation("Priority", ReasonBadPriority, "background notifications cannot use priority 10")
+	}
+
+	maxSize := MaxPayloadSize
+	if n.PushType == PushTypeVOIP {
+		maxSize = MaxVOIPPayloadSize
+	}
+	switch {
+	case len(payload) == 0 || string(payload) == "null":
+		violation("Payload", ReasonPayloadEmpty, "payload is empty")
+	case len(payload) > maxSize:
+		violation("Payload", ReasonPayloadTooLarge, "payload is %v bytes, exceeding %v", len(payload), maxSize)
+	}
+
+	if suffix, ok := topicSuffixes[n.PushType]; ok && n.Topic != "" && !strings.HasSuffix(n.Topic, suffix) {
+		violation("Topic", ReasonBadTopic, "topic %q of a %v notification must end with %q", n.Topic, n.PushType, suffix)
+	}
+
+	return errs
+}
@@ -0,0 +1,154 @@
+package apns2_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	apns "github.com/sapienzaapps/apns2"
+)
+
+func validationReasons(err error) []string {
+	var errs apns.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	reasons := make([]string, len(errs))
+	for i, e := range errs {
+		reasons[i] = e.Reason
+	}
+	return reasons
+}
+
+func mockPayload(size int) []byte {
+	return []byte(`{"aps":{},"pad":"` + strings.Repeat("x", size-19) + `"}`)
+}
+
+func TestValidateValidNotification(t *testing.T) {
+	n := mockNotification()
+	n.ApnsID = "84DB694F-464F-49BD-960A-D6DB028335C9"
+	n.CollapseID = "game1.start.identifier"
+	n.Topic = "com.testapp.voip"
+	n.PushType = apns.PushTypeVOIP
+	n.Priority = apns.PriorityHigh
+	if err := n.Validate(); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	scenarios := []struct {
+		name   string
+		modify func(n *apns.Notification)
+		reason string
+	}{
+		{"missing token", func(n *apns.Notification) { n.DeviceToken = "" }, apns.ReasonMissingDeviceToken},
+		{"non hex token", func(n *apns.Notification) { n.DeviceToken = strings.Repeat("z", 64) }, apns.ReasonBadDeviceToken},
+		{"odd token", func(n *apns.Notification) { n.DeviceToken = strings.Repeat("a", 65) }, apns.ReasonBadDeviceToken},
+		{"short token", func(n *apns.Notification) { n.DeviceToken = "aabb" }, apns.ReasonBadDeviceToken},
+		{"collapse id", func(n *apns.Notification) { n.CollapseID = strings.Repeat("c", 65) }, apns.ReasonBadCollapseID},
+		{"apns id", func(n *apns.Notification) { n.ApnsID = "not-a-uuid" }, apns.ReasonBadMessageID},
+		{"priority", func(n *apns.Notification) { n.Priority = 7 }, apns.ReasonBadPriority},
+		{"background priority", func(n *apns.Notification) {
+			n.PushType = apns.PushTypeBackground
+			n.Priority = apns.PriorityHigh
+		}, apns.ReasonBadPriority},
+		{"empty payload", func(n *apns.Notification) { n.Payload = []byte{} }, apns.ReasonPayloadEmpty},
+		{"nil payload", func(n *apns.Notification) { n.Payload = nil }, apns.ReasonPayloadEmpty},
+		{"large payload", func(n *apns.Notification) { n.Payload = mockPayload(apns.MaxPayloadSize + 1) }, apns.ReasonPayloadTooLarge},
+		{"large voip payload", func(n *apns.Notification) {
+			n.PushType = apns.PushTypeVOIP
+			n.Payload = mockPayload(apns.MaxVOIPPayloadSize + 1)
+		}, apns.ReasonPayloadTooLarge},
+		{"voip topic", func(n *apns.Notification) {
+			n.PushType = apns.PushTypeVOIP
+			n.Topic = "com.testapp"
+		}, apns.ReasonBadTopic},
+		{"complication topic", func(n *apns.Notification) {
+			n.PushType = apns.PushTypeComplication
+			n.Topic = "com.testapp"
+		}, apns.ReasonBadTopic},
+		{"fileprovider topic", func(n *apns.Notification) {
+			n.PushType = apns.PushTypeFileProvider
+			n.Topic = "com.testapp.fileprovider"
+		}, apns.ReasonBadTopic},
+	}
+
+	for _, scenario := range scenarios {
+		n := mockNotification()
+		scenario.modify(n)
+		reasons := validationReasons(n.Validate())
+		if 1 != len(reasons) || scenario.reason != reasons[0] {
+			t.Fatal("Expected:", scenario.reason, " found:", reasons, "for", scenario.name)
+		}
+	}
+}
+
+func TestValidateVOIPPayloadSize(t *testing.T) {
+	n := mockNotification()
+	n.PushType = apns.PushTypeVOIP
+	n.Payload = mockPayload(apns.MaxPayloadSize + 1)
+	if err := n.Validate(); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+}
+
+func TestValidateMultipleViolations(t *testing.T) {
+	n := mockNotification()
+	n.DeviceToken = "zz"
+	n.CollapseID = strings.Repeat("c", 65)
+	n.Priority = 3
+	err := n.Validate()
+	reasons := validationReasons(err)
+	if 3 != len(reasons) {
+		t.Fatal("Expected:", 3, " found:", reasons)
+	}
+	if !errors.Is(err, apns.ErrBadCollapseID) {
+		t.Fatal("Expected error to match", apns.ErrBadCollapseID)
+	}
+	var validationErr *apns.ValidationError
+	if !errors.As(err, &validationErr) || "DeviceToken" != validationErr.Field {
+		t.Fatal("Expected a *ValidationError for DeviceToken, found:", validationErr)
+	}
+}
+
+func TestValidateBadPayload(t *testing.T) {
+	n := mockNotification()
+	n.Payload = func() {}
+	if err := n.Validate(); err == nil {
+		t.Fatal("Expected error, found nil")
+	}
+}
+
+func TestClientValidateNotifications(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+
+	client := mockClient(server.URL)
+	client.ValidateNotifications = true
+	n := mockNotification()
+	n.Priority = 3
+	res, err := client.Push(n)
+	if !errors.Is(err, apns.ErrBadPriority) {
+		t.Fatal("Expected:", apns.ErrBadPriority, " found:", err)
+	}
+	if res != nil {
+		t.Fatal("res expected nil, found:", res)
+	}
+	if 0 != atomic.LoadInt32(&requests) {
+		t.Fatal("Expected:", 0, " found:", requests)
+	}
+
+	if _, err := client.Push(mockNotification()); err != nil {
+		t.Fatal("Expected no error, found:", err)
+	}
+	if 1 != atomic.LoadInt32(&requests) {
+		t.Fatal("Expected:", 1, " found:", requests)
+	}
+}